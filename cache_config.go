@@ -0,0 +1,135 @@
+package wasmtime
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// CacheConfig configures wasmtime's built-in cache of compiled code, as an
+// alternative to writing its TOML configuration file by hand. Zero fields
+// are left out of the file, so wasmtime's defaults apply to them.
+//
+// See https://docs.wasmtime.dev/cli-cache.html for the meaning of each
+// setting.
+type CacheConfig struct {
+	// The directory the cache is stored in.
+	Directory string
+	// How often the cache is cleaned up to stay within its limits.
+	CleanupInterval time.Duration
+	// The size, in bytes, the cache is cleaned up to stay under.
+	FilesTotalSizeSoftLimit uint64
+	// The number of files the cache is cleaned up to stay under.
+	FileCountSoftLimit uint64
+}
+
+// TOML returns the configuration file for `c`, as read by
+// `Config.CacheConfigLoad`.
+func (c CacheConfig) TOML() string {
+	var s strings.Builder
+	s.WriteString("[cache]\nenabled = true\n")
+	if c.Directory != "" {
+		fmt.Fprintf(&s, "directory = %s\n", tomlString(c.Directory))
+	}
+	if c.CleanupInterval > 0 {
+		// wasmtime only accepts whole units, so round up to a second.
+		seconds := (c.CleanupInterval + time.Second - 1) / time.Second
+		fmt.Fprintf(&s, "cleanup-interval = \"%ds\"\n", seconds)
+	}
+	if c.FilesTotalSizeSoftLimit > 0 {
+		fmt.Fprintf(&s, "files-total-size-soft-limit = \"%d\"\n", c.FilesTotalSizeSoftLimit)
+	}
+	if c.FileCountSoftLimit > 0 {
+		fmt.Fprintf(&s, "file-count-soft-limit = \"%d\"\n", c.FileCountSoftLimit)
+	}
+	return s.String()
+}
+
+// WriteFile writes the configuration file for `c` to `path`.
+func (c CacheConfig) WriteFile(path string) error {
+	return os.WriteFile(path, []byte(c.TOML()), 0o644)
+}
+
+// tomlString quotes `s` as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// CacheConfigLoad enables wasmtime's built-in cache of compiled code, reading
+// its configuration from the TOML file at `path`.
+//
+// An error satisfying `errors.Is(err, ErrUnsupported)` is returned if the
+// library was built without the cache.
+func (cfg *Config) CacheConfigLoad(path string) error {
+	if strings.IndexByte(path, 0) >= 0 {
+		return fmt.Errorf("cache config path %q contains a NUL byte", path)
+	}
+	cpath := append([]byte(path), 0)
+	return cfg.cacheConfigLoad(&cpath[0])
+}
+
+// CacheConfigLoadDefault enables wasmtime's built-in cache of compiled code
+// with its default configuration file, creating the file if it doesn't exist.
+//
+// An error satisfying `errors.Is(err, ErrUnsupported)` is returned if the
+// library was built without the cache.
+func (cfg *Config) CacheConfigLoadDefault() error {
+	return cfg.cacheConfigLoad(nil)
+}
+
+// SetCacheConfig enables wasmtime's built-in cache of compiled code
+// configured by `c`.
+//
+// An error satisfying `errors.Is(err, ErrUnsupported)` is returned if the
+// library was built without the cache.
+func (cfg *Config) SetCacheConfig(c CacheConfig) error {
+	if err := checkFeature(FeatureCache); err != nil {
+		return err
+	}
+	// wasmtime reads the file while loading it, so it isn't needed after.
+	f, err := os.CreateTemp("", "wasmtime-cache-*.toml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(c.TOML())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return cfg.CacheConfigLoad(f.Name())
+}
+
+// cacheConfigLoad loads the configuration at the C string `path`, or the
+// default one if `path` is nil.
+func (cfg *Config) cacheConfigLoad(path *byte) error {
+	if err := checkFeature(FeatureCache); err != nil {
+		return err
+	}
+	err := wasmtime_config_cache_config_load(uintptr(cfg.ptr()), path)
+	runtime.KeepAlive(cfg)
+	runtime.KeepAlive(path)
+	if err != 0 {
+		return mkError(unsafe.Pointer(err))
+	}
+	return nil
+}
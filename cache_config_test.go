@@ -0,0 +1,54 @@
+package wasmtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hybridgroup/wasmtime/internal/wasmtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheConfigTOML(t *testing.T) {
+	require.Equal(t, "[cache]\nenabled = true\n", CacheConfig{}.TOML())
+
+	c := CacheConfig{
+		Directory:               `C:\cache "wasm"`,
+		CleanupInterval:         90*time.Minute + time.Millisecond,
+		FilesTotalSizeSoftLimit: 1 << 30,
+		FileCountSoftLimit:      1000,
+	}
+	require.Equal(t, `[cache]
+enabled = true
+directory = "C:\\cache \"wasm\""
+cleanup-interval = "5401s"
+files-total-size-soft-limit = "1073741824"
+file-count-soft-limit = "1000"
+`, c.TOML())
+
+	require.Equal(t, `"a\u0009b"`, tomlString("a\tb"))
+
+	path := filepath.Join(t.TempDir(), "cache.toml")
+	require.NoError(t, c.WriteFile(path))
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, c.TOML(), string(contents))
+}
+
+func TestCacheConfigLoad(t *testing.T) {
+	if !Features()[FeatureCache] {
+		t.Skip("the wasmtime library was built without the cache")
+	}
+	dir := t.TempDir()
+	config := NewConfig()
+	defer config.Close()
+	require.NoError(t, config.SetCacheConfig(CacheConfig{Directory: dir, CleanupInterval: time.Hour}))
+	require.Error(t, config.CacheConfigLoad(filepath.Join(dir, "missing.toml")))
+	require.Error(t, config.CacheConfigLoad("nul\x00byte"))
+
+	engine := NewEngineWithConfig(config)
+	defer engine.Close()
+	_, err := NewModule(engine, wasmtest.Module())
+	require.NoError(t, err)
+}
@@ -0,0 +1,230 @@
+package wasmtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// Strategy is the compilation strategies for wasmtime
+type Strategy uint8
+
+const (
+	// StrategyAuto will let wasmtime automatically pick an appropriate compilation strategy
+	StrategyAuto Strategy = 0
+	// StrategyCranelift will force wasmtime to use the Cranelift backend
+	StrategyCranelift Strategy = 1
+)
+
+// OptLevel decides what degree of optimization wasmtime will perform on generated machine code
+type OptLevel uint8
+
+const (
+	// OptLevelNone will perform no optimizations
+	OptLevelNone OptLevel = 0
+	// OptLevelSpeed will optimize machine code to be as fast as possible
+	OptLevelSpeed OptLevel = 1
+	// OptLevelSpeedAndSize will optimize machine code for speed, but also optimize
+	// to be small, sometimes at the cost of speed.
+	OptLevelSpeedAndSize OptLevel = 2
+)
+
+// Config holds options used to create an Engine and customize its behavior.
+type Config struct {
+	_ptr unsafe.Pointer // *C.wasm_config_t
+	// The settings applied so far, from which `Fingerprint` is computed
+	// since the C API can't read a configuration back.
+	settings map[string]string
+}
+
+// NewConfig creates a new `Config` with all default options configured.
+func NewConfig() *Config {
+	mustLoad()
+	config := &Config{_ptr: unsafe.Pointer(wasm_config_new()), settings: make(map[string]string)}
+	runtime.SetFinalizer(config, func(config *Config) {
+		config.Close()
+	})
+	return config
+}
+
+// set records that the setting `name` was given `value`.
+func (cfg *Config) set(name string, value interface{}) {
+	cfg.settings[name] = fmt.Sprint(value)
+}
+
+// SetDebugInfo configures whether dwarf debug information for JIT code is enabled
+func (cfg *Config) SetDebugInfo(enabled bool) {
+	wasmtime_config_debug_info_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("debug_info", enabled)
+}
+
+// SetWasmReferenceTypes configures whether the wasm reference types proposal is enabled
+func (cfg *Config) SetWasmReferenceTypes(enabled bool) {
+	wasmtime_config_wasm_reference_types_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("wasm_reference_types", enabled)
+}
+
+// SetWasmSIMD configures whether the wasm SIMD proposal is enabled
+func (cfg *Config) SetWasmSIMD(enabled bool) {
+	wasmtime_config_wasm_simd_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("wasm_simd", enabled)
+}
+
+// SetWasmRelaxedSIMD configures whether the wasm relaxed SIMD proposal is enabled
+func (cfg *Config) SetWasmRelaxedSIMD(enabled bool) {
+	wasmtime_config_wasm_relaxed_simd_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("wasm_relaxed_simd", enabled)
+}
+
+// SetWasmBulkMemory configures whether the wasm bulk memory proposal is enabled
+func (cfg *Config) SetWasmBulkMemory(enabled bool) {
+	wasmtime_config_wasm_bulk_memory_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("wasm_bulk_memory", enabled)
+}
+
+// SetWasmMultiValue configures whether the wasm multi value proposal is enabled
+func (cfg *Config) SetWasmMultiValue(enabled bool) {
+	wasmtime_config_wasm_multi_value_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("wasm_multi_value", enabled)
+}
+
+// SetWasmMultiMemory configures whether the wasm multi memory proposal is enabled
+func (cfg *Config) SetWasmMultiMemory(enabled bool) {
+	wasmtime_config_wasm_multi_memory_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("wasm_multi_memory", enabled)
+}
+
+// SetWasmMemory64 configures whether the wasm memory64 proposal is enabled
+func (cfg *Config) SetWasmMemory64(enabled bool) {
+	wasmtime_config_wasm_memory64_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("wasm_memory64", enabled)
+}
+
+// SetWasmTailCall configures whether the wasm tail call proposal is enabled
+func (cfg *Config) SetWasmTailCall(enabled bool) {
+	wasmtime_config_wasm_tail_call_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("wasm_tail_call", enabled)
+}
+
+// SetConsumeFuel configures whether fuel is enabled
+func (cfg *Config) SetConsumeFuel(enabled bool) {
+	wasmtime_config_consume_fuel_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("consume_fuel", enabled)
+}
+
+// SetEpochInterruption enables epoch-based instrumentation of generated code to
+// interrupt WebAssembly execution when the current engine epoch exceeds a
+// defined threshold.
+func (cfg *Config) SetEpochInterruption(enable bool) {
+	wasmtime_config_epoch_interruption_set(uintptr(cfg.ptr()), enable)
+	runtime.KeepAlive(cfg)
+	cfg.set("epoch_interruption", enable)
+}
+
+// SetMaxWasmStack configures the maximum stack size, in bytes, that JIT code can use.
+func (cfg *Config) SetMaxWasmStack(size uintptr) {
+	wasmtime_config_max_wasm_stack_set(uintptr(cfg.ptr()), size)
+	runtime.KeepAlive(cfg)
+	cfg.set("max_wasm_stack", size)
+}
+
+// SetStrategy configures what compilation strategy is used to compile wasm code
+func (cfg *Config) SetStrategy(strat Strategy) {
+	wasmtime_config_strategy_set(uintptr(cfg.ptr()), uint8(strat))
+	runtime.KeepAlive(cfg)
+	cfg.set("strategy", strat)
+}
+
+// SetCraneliftNanCanonicalization configures whether whether Cranelift should perform a
+// NaN-canonicalization pass.
+//
+// When Cranelift is used as a code generation backend this will configure it to replace NaNs with a single
+// canonical value. This is useful for users requiring entirely deterministic WebAssembly computation.
+//
+// This is not required by the WebAssembly spec, so it is not enabled by default.
+func (cfg *Config) SetCraneliftNanCanonicalization(enabled bool) {
+	wasmtime_config_cranelift_nan_canonicalization_set(uintptr(cfg.ptr()), enabled)
+	runtime.KeepAlive(cfg)
+	cfg.set("cranelift_nan_canonicalization", enabled)
+}
+
+// SetCraneliftOptLevel configures the cranelift optimization level for generated code
+func (cfg *Config) SetCraneliftOptLevel(level OptLevel) {
+	wasmtime_config_cranelift_opt_level_set(uintptr(cfg.ptr()), uint8(level))
+	runtime.KeepAlive(cfg)
+	cfg.set("cranelift_opt_level", level)
+}
+
+// SetTarget configures the target triple that this configuration will produce
+// machine code for.
+//
+// This option defaults to the native host. Calling this method will
+// additionally disable inference of the native features of the host (e.g.
+// detection of SSE4.2 on x86_64 hosts).
+func (cfg *Config) SetTarget(target string) error {
+	err := wasmtime_config_target_set(uintptr(cfg.ptr()), target)
+	runtime.KeepAlive(cfg)
+	if err != 0 {
+		return mkError(unsafe.Pointer(err))
+	}
+	cfg.set("target", target)
+	return nil
+}
+
+// Fingerprint returns a digest of the settings applied to this configuration.
+// Configurations with the same settings have the same fingerprint, so it can
+// be used to tell whether code compiled with one engine is compatible with
+// another, for example as part of a cache key.
+func (cfg *Config) Fingerprint() string {
+	return configFingerprint(cfg.settings)
+}
+
+// configFingerprint hashes `settings` in a canonical order.
+func configFingerprint(settings map[string]string) string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var s strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&s, "%s=%s\n", name, settings[name])
+	}
+	sum := sha256.Sum256([]byte(s.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Close will deallocate this config's state explicitly.
+//
+// For more information see the documentation for engine.Close()
+func (cfg *Config) Close() {
+	if cfg._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(cfg, nil)
+	wasm_config_delete(uintptr(cfg._ptr))
+	cfg._ptr = nil
+}
+
+func (cfg *Config) ptr() unsafe.Pointer {
+	ret := cfg._ptr
+	if ret == nil {
+		panic("Config has already been used or closed")
+	}
+	//maybeGC()
+	return ret
+}
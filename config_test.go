@@ -0,0 +1,46 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFingerprint(t *testing.T) {
+	a := &Config{settings: map[string]string{}}
+	b := &Config{settings: map[string]string{}}
+	require.Equal(t, a.Fingerprint(), b.Fingerprint())
+	require.Equal(t, configFingerprint(nil), a.Fingerprint())
+
+	// The order settings are applied in doesn't matter.
+	a.set("wasm_simd", false)
+	a.set("consume_fuel", true)
+	b.set("consume_fuel", true)
+	b.set("wasm_simd", false)
+	require.Equal(t, a.Fingerprint(), b.Fingerprint())
+
+	b.set("consume_fuel", false)
+	require.NotEqual(t, a.Fingerprint(), b.Fingerprint())
+}
+
+func TestConfig(t *testing.T) {
+	config := NewConfig()
+	config.SetDebugInfo(true)
+	config.SetWasmReferenceTypes(true)
+	config.SetWasmSIMD(true)
+	config.SetWasmRelaxedSIMD(true)
+	config.SetWasmBulkMemory(true)
+	config.SetWasmMultiValue(true)
+	config.SetWasmMultiMemory(true)
+	config.SetWasmMemory64(true)
+	config.SetWasmTailCall(true)
+	config.SetConsumeFuel(true)
+	config.SetEpochInterruption(true)
+	config.SetMaxWasmStack(1 << 20)
+	config.SetStrategy(StrategyCranelift)
+	config.SetCraneliftOptLevel(OptLevelSpeedAndSize)
+	config.SetCraneliftNanCanonicalization(true)
+	require.Error(t, config.SetTarget("not-a-target"))
+	NewEngineWithConfig(config).Close()
+	config.Close()
+}
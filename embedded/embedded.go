@@ -0,0 +1,143 @@
+// Package embedded ships the wasmtime dynamic library inside the Go binary.
+//
+// Importing this package for its side effects makes the wasmtime package load
+// the library embedded here instead of searching the standard system paths:
+//
+//	import _ "github.com/hybridgroup/wasmtime/embedded"
+//
+// On first use the library is verified against the SHA-256 digest pinned for
+// it at build time and extracted to a directory under the user's cache
+// directory, from where it's loaded. Later runs reuse the extracted copy as long as it's still intact.
+//
+// If no library is embedded for the current platform then importing this
+// package has no effect and the library is searched for as usual.
+package embedded
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hybridgroup/wasmtime"
+)
+
+// libVersion is the version of the embedded wasmtime libraries, which is kept
+// up to date by `update.sh`.
+const libVersion = "33.0.0"
+
+func init() {
+	if Available() {
+		wasmtime.RegisterLibraryProvider(Path)
+	}
+}
+
+// Available reports whether a wasmtime library is embedded for the current
+// platform.
+func Available() bool {
+	if libName == "" {
+		return false
+	}
+	_, err := fs.Stat(libFS, path.Join(libDir, libName))
+	return err == nil
+}
+
+var extractOnce sync.Once
+var extractPath string
+var extractErr error
+
+// Path returns the path of the embedded wasmtime library, extracting it to
+// the user's cache directory if that hasn't happened yet.
+func Path() (string, error) {
+	extractOnce.Do(func() {
+		if !Available() {
+			extractErr = errors.New("no wasmtime library is embedded for this platform")
+			return
+		}
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			cacheDir = os.TempDir()
+		}
+		extractPath, extractErr = extract(libFS, libDir, libName, libVersion, libSHA256, filepath.Join(cacheDir, "wasmtime-purego"))
+	})
+	return extractPath, extractErr
+}
+
+// versionedName returns the file name `name` of a library with `version`
+// added to it, in the style of the platform's versioned library names, so
+// that the wasmtime package can tell which version it's loading.
+func versionedName(name, version string) string {
+	for _, ext := range []string{".dylib", ".dll"} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext) + "." + version + ext
+		}
+	}
+	return name + "." + version
+}
+
+// extract verifies the library `name` in `dir` of `fsys` against `sum`, the
+// hex-encoded SHA-256 digest pinned for it at build time, and writes it, named
+// for `version`, to a directory under `cacheDir` named after that digest,
+// returning the written file's path.
+//
+// A previously extracted library is reused if its contents still match the
+// digest, and replaced otherwise.
+func extract(fsys fs.FS, dir, name, version, sum, cacheDir string) (string, error) {
+	if sum == "" {
+		return "", fmt.Errorf("no checksum is pinned for embedded %s", name)
+	}
+	want, err := hex.DecodeString(sum)
+	if err != nil || len(want) != sha256.Size {
+		return "", fmt.Errorf("malformed checksum for embedded %s", name)
+	}
+	lib, err := fs.ReadFile(fsys, path.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	if got := sha256.Sum256(lib); !bytes.Equal(got[:], want) {
+		return "", fmt.Errorf("embedded %s doesn't match its checksum", name)
+	}
+
+	target := filepath.Join(cacheDir, hex.EncodeToString(want), versionedName(name, version))
+	if existing, err := os.ReadFile(target); err == nil {
+		if got := sha256.Sum256(existing); bytes.Equal(got[:], want) {
+			return target, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	// Write to a temporary file first and rename it into place so that
+	// concurrent processes never load a partially written library.
+	tmp, err := os.CreateTemp(filepath.Dir(target), name+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(lib); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
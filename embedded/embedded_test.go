@@ -0,0 +1,74 @@
+package embedded
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testFS(lib []byte) (fstest.MapFS, string) {
+	sum := sha256.Sum256(lib)
+	return fstest.MapFS{
+		"lib/test/libexample.so": {Data: lib},
+	}, hex.EncodeToString(sum[:])
+}
+
+func TestExtract(t *testing.T) {
+	cacheDir := t.TempDir()
+	lib := []byte("not really a library")
+	fsys, sum := testFS(lib)
+
+	path, err := extract(fsys, "lib/test", "libexample.so", "33.0.0", sum, cacheDir)
+	require.NoError(t, err)
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, lib, contents)
+	require.Equal(t, "libexample.so.33.0.0", filepath.Base(path))
+
+	// The extracted copy is reused on the next run.
+	again, err := extract(fsys, "lib/test", "libexample.so", "33.0.0", sum, cacheDir)
+	require.NoError(t, err)
+	require.Equal(t, path, again)
+
+	// A corrupted copy is replaced.
+	require.NoError(t, os.WriteFile(path, []byte("corrupted"), 0o755))
+	again, err = extract(fsys, "lib/test", "libexample.so", "33.0.0", sum, cacheDir)
+	require.NoError(t, err)
+	require.Equal(t, path, again)
+	contents, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, lib, contents)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary files should be cleaned up")
+}
+
+func TestExtractChecksum(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	fsys, sum := testFS([]byte("library"))
+	fsys["lib/test/libexample.so"] = &fstest.MapFile{Data: []byte("tampered")}
+	_, err := extract(fsys, "lib/test", "libexample.so", "33.0.0", sum, cacheDir)
+	require.ErrorContains(t, err, "doesn't match its checksum")
+
+	// Only the pinned digest is trusted, whatever is embedded next to the
+	// library.
+	fsys, _ = testFS([]byte("library"))
+	_, err = extract(fsys, "lib/test", "libexample.so", "33.0.0", "xyz", cacheDir)
+	require.ErrorContains(t, err, "malformed checksum")
+
+	_, err = extract(fsys, "lib/test", "libexample.so", "33.0.0", "", cacheDir)
+	require.ErrorContains(t, err, "no checksum is pinned")
+}
+
+func TestVersionedName(t *testing.T) {
+	require.Equal(t, "libwasmtime.so.33.0.0", versionedName("libwasmtime.so", "33.0.0"))
+	require.Equal(t, "libwasmtime.33.0.0.dylib", versionedName("libwasmtime.dylib", "33.0.0"))
+	require.Equal(t, "wasmtime.33.0.0.dll", versionedName("wasmtime.dll", "33.0.0"))
+}
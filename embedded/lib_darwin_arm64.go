@@ -0,0 +1,15 @@
+//go:build darwin && arm64
+
+package embedded
+
+import "embed"
+
+//go:embed all:lib/darwin_arm64
+var libFS embed.FS
+
+const (
+	libDir  = "lib/darwin_arm64"
+	libName = "libwasmtime.dylib"
+	// The SHA-256 digest of the library, kept up to date by `update.sh`.
+	libSHA256 = ""
+)
@@ -0,0 +1,15 @@
+//go:build linux && amd64
+
+package embedded
+
+import "embed"
+
+//go:embed all:lib/linux_amd64
+var libFS embed.FS
+
+const (
+	libDir  = "lib/linux_amd64"
+	libName = "libwasmtime.so"
+	// The SHA-256 digest of the library, kept up to date by `update.sh`.
+	libSHA256 = ""
+)
@@ -0,0 +1,15 @@
+//go:build linux && arm64
+
+package embedded
+
+import "embed"
+
+//go:embed all:lib/linux_arm64
+var libFS embed.FS
+
+const (
+	libDir  = "lib/linux_arm64"
+	libName = "libwasmtime.so"
+	// The SHA-256 digest of the library, kept up to date by `update.sh`.
+	libSHA256 = ""
+)
@@ -0,0 +1,14 @@
+//go:build !(linux && (amd64 || arm64)) && !(darwin && (amd64 || arm64)) && !(windows && amd64)
+
+package embedded
+
+import "embed"
+
+// There's no wasmtime release for this platform to embed.
+var libFS embed.FS
+
+const (
+	libDir    = ""
+	libName   = ""
+	libSHA256 = ""
+)
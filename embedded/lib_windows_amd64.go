@@ -0,0 +1,15 @@
+//go:build windows && amd64
+
+package embedded
+
+import "embed"
+
+//go:embed all:lib/windows_amd64
+var libFS embed.FS
+
+const (
+	libDir  = "lib/windows_amd64"
+	libName = "wasmtime.dll"
+	// The SHA-256 digest of the library, kept up to date by `update.sh`.
+	libSHA256 = ""
+)
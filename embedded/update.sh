@@ -0,0 +1,52 @@
+#!/bin/sh
+# Downloads the wasmtime C API release for every supported platform and
+# places its dynamic library in the matching lib/<os>_<arch> directory to be
+# embedded, pinning the library's SHA-256 digest in lib_<os>_<arch>.go.
+#
+# Each downloaded archive is verified against the digest pinned for it in
+# SHA256SUMS, copied from the release's page, before anything is extracted
+# from it. Updating to a new release means adding its digests there first.
+#
+# usage: ./update.sh [version]
+set -eu
+
+VERSION="${1:-33.0.0}"
+BASE="https://github.com/bytecodealliance/wasmtime/releases/download/v${VERSION}"
+cd "$(dirname "$0")"
+
+fetch() {
+	dir="$1" release="$2" archive="$3" lib="$4"
+	name="wasmtime-v${VERSION}-${release}-c-api.${archive}"
+	sum="$(awk -v name="${name}" '$2 == name { print $1 }' SHA256SUMS)"
+	if [ -z "${sum}" ]; then
+		echo "no checksum pinned for ${name} in SHA256SUMS" >&2
+		exit 1
+	fi
+	tmp="$(mktemp -d)"
+	curl -fsSL "${BASE}/${name}" -o "${tmp}/${name}"
+	if ! (cd "${tmp}" && echo "${sum}  ${name}" | sha256sum -c --quiet -); then
+		echo "${name} doesn't match its pinned checksum" >&2
+		rm -rf "${tmp}"
+		exit 1
+	fi
+	case "${archive}" in
+	tar.xz) tar -xJf "${tmp}/${name}" -C "${tmp}" ;;
+	zip) unzip -q "${tmp}/${name}" -d "${tmp}" ;;
+	esac
+	cp "${tmp}/wasmtime-v${VERSION}-${release}-c-api/lib/${lib}" "lib/${dir}/${lib}"
+	libsum="$(sha256sum "lib/${dir}/${lib}" | cut -d' ' -f1)"
+	sed -i.bak "s/libSHA256 = \".*\"/libSHA256 = \"${libsum}\"/" "lib_${dir}.go"
+	rm "lib_${dir}.go.bak"
+	rm -rf "${tmp}"
+}
+
+fetch linux_amd64 x86_64-linux tar.xz libwasmtime.so
+fetch linux_arm64 aarch64-linux tar.xz libwasmtime.so
+fetch darwin_amd64 x86_64-macos tar.xz libwasmtime.dylib
+fetch darwin_arm64 aarch64-macos tar.xz libwasmtime.dylib
+fetch windows_amd64 x86_64-windows zip wasmtime.dll
+
+# The extracted library is named for its version, which the wasmtime package
+# checks when loading it.
+sed -i.bak "s/^const libVersion = \".*\"/const libVersion = \"${VERSION}\"/" embedded.go
+rm embedded.go.bak
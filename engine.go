@@ -11,11 +11,28 @@ import (
 // and such.
 type Engine struct {
 	_ptr unsafe.Pointer //*C.wasm_engine_t
+	// The fingerprint of the `Config` the engine was created with.
+	fingerprint string
 }
 
 // NewEngine creates a new `Engine` with default configuration.
 func NewEngine() *Engine {
-	engine := &Engine{_ptr: unsafe.Pointer(wasm_engine_new())}
+	mustLoad()
+	return mkEngine(wasm_engine_new(), configFingerprint(nil))
+}
+
+// NewEngineWithConfig creates a new `Engine` with the `Config` provided
+//
+// Note that once a `Config` is passed to this method it cannot be used again.
+func NewEngineWithConfig(config *Config) *Engine {
+	ptr := wasm_engine_new_with_config(uintptr(config.ptr()))
+	runtime.SetFinalizer(config, nil)
+	config._ptr = nil
+	return mkEngine(ptr, config.Fingerprint())
+}
+
+func mkEngine(ptr uintptr, fingerprint string) *Engine {
+	engine := &Engine{_ptr: unsafe.Pointer(ptr), fingerprint: fingerprint}
 	runtime.SetFinalizer(engine, func(engine *Engine) {
 		engine.Close()
 	})
@@ -47,6 +64,15 @@ func (engine *Engine) Close() {
 
 }
 
+// IncrementEpoch advances the epoch of this engine by one tick, interrupting
+// wasm in stores whose epoch deadline has been reached.
+//
+// This is safe to call from any goroutine, for example from a ticker.
+func (engine *Engine) IncrementEpoch() {
+	wasmtime_engine_increment_epoch(uintptr(engine.ptr()))
+	runtime.KeepAlive(engine)
+}
+
 func (engine *Engine) ptr() unsafe.Pointer {
 	ret := engine._ptr
 	if ret == nil {
@@ -2,11 +2,27 @@ package wasmtime
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestEngine(t *testing.T) {
 	engine := NewEngine()
 	defer engine.Close()
-	//NewEngineWithConfig(NewConfig())
+	engine = NewEngineWithConfig(NewConfig())
+	defer engine.Close()
 	//engine.IsPulley()
 }
+
+func TestEngineFingerprint(t *testing.T) {
+	require.Equal(t, NewConfig().Fingerprint(), NewEngine().fingerprint)
+
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	fingerprint := config.Fingerprint()
+	require.NotEqual(t, NewConfig().Fingerprint(), fingerprint)
+	engine := NewEngineWithConfig(config)
+	defer engine.Close()
+	require.Equal(t, fingerprint, engine.fingerprint)
+	require.Panics(t, func() { config.SetConsumeFuel(false) })
+}
@@ -30,5 +30,18 @@ func (e *Error) Close() {
 }
 
 func (e *Error) Error() string {
-	return "need error message implementation"
+	var message wasm_byte_vec_t
+	wasmtime_error_message(uintptr(e.ptr()), &message)
+	ret := string(unsafe.Slice(message.data, message.size))
+	wasm_byte_vec_delete(&message)
+	runtime.KeepAlive(e)
+	return ret
+}
+
+func (e *Error) ptr() unsafe.Pointer {
+	ret := e._ptr
+	if ret == nil {
+		panic("object has been closed already")
+	}
+	return ret
 }
@@ -0,0 +1,58 @@
+package wasmtime
+
+import (
+	"unsafe"
+)
+
+const (
+	externKindFunc         uint8 = 0 // WASMTIME_EXTERN_FUNC
+	externKindGlobal       uint8 = 1 // WASMTIME_EXTERN_GLOBAL
+	externKindTable        uint8 = 2 // WASMTIME_EXTERN_TABLE
+	externKindMemory       uint8 = 3 // WASMTIME_EXTERN_MEMORY
+	externKindSharedMemory uint8 = 4 // WASMTIME_EXTERN_SHAREDMEMORY
+)
+
+type wasmtime_extern_t struct {
+	_    [0]uint64 // aligns the struct to 8 bytes, as in C
+	kind uint8     // C.wasmtime_extern_kind_t
+	_    [7]byte   // padding to 8 bytes
+	of   [16]byte  // C.wasmtime_extern_union_t
+}
+
+// As with `wasmtime_val_t`, the members of the `of` union are all stored at
+// its start, so each one is accessed by viewing the union as the member's type.
+func (e *wasmtime_extern_t) funcPtr() *wasmtime_func_t {
+	return (*wasmtime_func_t)(unsafe.Pointer(&e.of))
+}
+
+func (e *wasmtime_extern_t) memoryPtr() *wasmtime_memory_t {
+	return (*wasmtime_memory_t)(unsafe.Pointer(&e.of))
+}
+
+// Extern is an external value, which is the runtime representation of an entity that can be imported or exported.
+// It is an address denoting either a function instance, table instance, memory instance, or global instances in the shared store.
+// Read more in [spec](https://webassembly.github.io/spec/core/exec/runtime.html#external-values)
+type Extern struct {
+	val wasmtime_extern_t
+}
+
+func mkExtern(val *wasmtime_extern_t) *Extern {
+	return &Extern{val: *val}
+}
+
+// Func returns a Func if this export is a function or nil otherwise
+func (e *Extern) Func() *Func {
+	if e.val.kind != externKindFunc {
+		return nil
+	}
+	ret := *e.val.funcPtr()
+	return mkFunc(&ret)
+}
+
+// Memory returns a Memory if this export is a memory or nil otherwise
+func (e *Extern) Memory() *Memory {
+	if e.val.kind != externKindMemory {
+		return nil
+	}
+	return mkMemory(e.val.memoryPtr())
+}
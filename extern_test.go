@@ -0,0 +1,28 @@
+package wasmtime
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternLayout(t *testing.T) {
+	var e wasmtime_extern_t
+	require.Equal(t, uintptr(8), unsafe.Offsetof(e.of))
+	require.Equal(t, uintptr(16), unsafe.Sizeof(wasmtime_func_t{}))
+	require.Equal(t, uintptr(16), unsafe.Sizeof(wasmtime_memory_t{}))
+
+	e.kind = externKindFunc
+	e.funcPtr().store_id = 1
+	e.funcPtr().index = 2
+	f := mkExtern(&e).Func()
+	require.NotNil(t, f)
+	require.Equal(t, uint64(1), f.ptr().store_id)
+	require.Equal(t, 2, f.ptr().index)
+	require.Nil(t, mkExtern(&e).Memory())
+
+	e.kind = externKindMemory
+	require.Nil(t, mkExtern(&e).Func())
+	require.NotNil(t, mkExtern(&e).Memory())
+}
@@ -0,0 +1,70 @@
+package wasmtime
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Feature is an optional part of wasmtime which the loaded library may have
+// been built without.
+type Feature string
+
+const (
+	// FeatureWat is support for the WebAssembly text format, used by
+	// `Wat2Wasm`.
+	FeatureWat Feature = "wat"
+	// FeatureCache is wasmtime's built-in cache of compiled code, configured
+	// by `Config.CacheConfigLoad`.
+	FeatureCache Feature = "cache"
+	// FeaturePoolingAllocator is the pooling instance allocator, configured
+	// by `Config.SetPoolingAllocationStrategy`.
+	FeaturePoolingAllocator Feature = "pooling-allocator"
+)
+
+// features records which optional features the loaded library supports.
+var features = make(map[Feature]bool)
+
+// Features reports which optional features the loaded wasmtime library
+// supports, loading it with the default options if that hasn't happened yet.
+//
+// APIs backed by a feature the library lacks return an error satisfying
+// `errors.Is(err, ErrUnsupported)`. Nil is returned if the library couldn't be
+// loaded.
+func Features() map[Feature]bool {
+	if err := Load(LoadOptions{}); err != nil {
+		return nil
+	}
+	ret := make(map[Feature]bool, len(features))
+	for feature, ok := range features {
+		ret[feature] = ok
+	}
+	return ret
+}
+
+// ErrUnsupported is matched, using `errors.Is`, by the errors returned from
+// APIs which the loaded wasmtime library doesn't support.
+var ErrUnsupported = errors.New("unsupported by the loaded wasmtime library")
+
+// UnsupportedError is returned from APIs backed by a `Feature` which the
+// loaded wasmtime library was built without.
+type UnsupportedError struct {
+	Feature Feature
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("the %q feature is %s", e.Feature, ErrUnsupported)
+}
+
+// Is makes `UnsupportedError` match `ErrUnsupported`.
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// checkFeature returns an `*UnsupportedError` if `feature` isn't supported by
+// the loaded library.
+func checkFeature(feature Feature) error {
+	if !features[feature] {
+		return &UnsupportedError{Feature: feature}
+	}
+	return nil
+}
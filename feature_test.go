@@ -0,0 +1,44 @@
+package wasmtime
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsupportedError(t *testing.T) {
+	err := checkFeature(Feature("does-not-exist"))
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrUnsupported))
+	var unsupported *UnsupportedError
+	require.True(t, errors.As(err, &unsupported))
+	require.Equal(t, Feature("does-not-exist"), unsupported.Feature)
+}
+
+func TestRegisterOptional(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses the C library of linux")
+	}
+	lib, err := load("libc.so.6")
+	if err != nil {
+		t.Skip("libc unavailable:", err)
+	}
+	defer delete(features, "test-present")
+	defer delete(features, "test-partial")
+
+	var strlen func(s string) int
+	registerOptional(&strlen, lib, "strlen", "test-present")
+	require.True(t, features["test-present"])
+	require.NoError(t, checkFeature("test-present"))
+	require.Equal(t, 5, strlen("hello"))
+
+	var missing func()
+	registerOptional(&strlen, lib, "strlen", "test-partial")
+	registerOptional(&missing, lib, "wasmtime_does_not_exist", "test-partial")
+	registerOptional(&strlen, lib, "strlen", "test-partial")
+	require.False(t, features["test-partial"])
+	require.ErrorIs(t, checkFeature("test-partial"), ErrUnsupported)
+	require.Nil(t, missing)
+}
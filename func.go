@@ -1,11 +1,13 @@
 package wasmtime
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"runtime"
+	"runtime/debug"
 	"unsafe"
-
-	"github.com/ebitengine/purego"
 )
 
 // Func is a function instance, which is the runtime representation of a function.
@@ -51,11 +53,38 @@ type wasmtime_func_t struct {
 // will be returned to the original caller.
 //
 // If the `f` callback panics then the panic will be propagated to the caller
-// as well.
+// as well, as a `*HostPanic` carrying the original panic value and stack.
+//
+// The `f` callback is retained by `store` until the store is closed, so a
+// long-lived store grows with every function created in it.
 func NewFunc(
 	store Storelike,
 	ty *FuncType,
 	f func(*Caller, []Val) ([]Val, *Trap),
+) *Func {
+	return newFunc(store, ty, func(_ context.Context, caller *Caller, args []Val) ([]Val, *Trap) {
+		return f(caller, args)
+	})
+}
+
+// NewFuncContext is the same as `NewFunc` except that `f` also receives the
+// `context.Context` given to the `Func.CallContext` that led to this host
+// function being invoked, or `context.Background()` if there isn't one.
+//
+// This allows host functions to perform cancellable work, such as database or
+// RPC calls, on behalf of the guest.
+func NewFuncContext(
+	store Storelike,
+	ty *FuncType,
+	f func(context.Context, *Caller, []Val) ([]Val, *Trap),
+) *Func {
+	return newFunc(store, ty, f)
+}
+
+func newFunc(
+	store Storelike,
+	ty *FuncType,
+	f func(context.Context, *Caller, []Val) ([]Val, *Trap),
 ) *Func {
 	idx := insertFuncNew(getDataInStore(store), ty, f)
 
@@ -63,7 +92,7 @@ func NewFunc(
 	wasmtime_func_new(
 		uintptr(store.Context()),
 		ty.ptr(),
-		purego.NewCallback(goTrampolineNew),
+		goTrampolineNewPtr,
 		idx,
 		0, // this is `NewFunc`
 		&ret,
@@ -75,10 +104,14 @@ func NewFunc(
 	return mkFunc(&ret)
 }
 
+// goTrampolineNew is the `wasmtime_func_callback_t` of functions created with
+// `NewFunc`. Like every wasmtime callback it receives its `env` first and the
+// caller second, followed by the parameters and results.
+//
 //export goTrampolineNew
 func goTrampolineNew(
-	callerPtr uintptr,
 	env int,
+	callerPtr uintptr,
 	argsPtr uintptr,
 	argsNum int,
 	resultsPtr uintptr,
@@ -98,10 +131,10 @@ func goTrampolineNew(
 
 	var results []Val
 	var trap *Trap
-	var lastPanic interface{}
+	var lastPanic *HostPanic
 	func() {
-		defer func() { lastPanic = recover() }()
-		results, trap = entry.callback(caller, params)
+		defer recoverHostPanic(&lastPanic)
+		results, trap = entry.callback(data.context(), caller, params)
 		if trap != nil {
 			if trap._ptr == nil {
 				panic("returned an already-returned trap")
@@ -117,17 +150,11 @@ func goTrampolineNew(
 			}
 		}
 	}()
-	if trap == nil && lastPanic != nil {
-		data.lastPanic = lastPanic
-		trap := NewTrap("go panicked")
-		runtime.SetFinalizer(trap, nil)
-		return uintptr(trap.ptr())
+	if lastPanic != nil {
+		return data.panicTrap(lastPanic)
 	}
 	if trap != nil {
-		runtime.SetFinalizer(trap, nil)
-		ret := trap.ptr()
-		trap._ptr = nil
-		return uintptr(ret)
+		return data.returnTrap(trap)
 	}
 
 	base = unsafe.Pointer(resultsPtr)
@@ -146,9 +173,12 @@ func goTrampolineNew(
 // provided must be a Go function. It may take any number of the following
 // types as arguments:
 //
-// `int32` - a wasm `i32`
+// `int32`, `uint32`, `bool` - a wasm `i32`
 //
-// `int64` - a wasm `i64`
+// `int64`, `uint64` - a wasm `i64`
+//
+// `int`, `uint`, `uintptr` - a wasm `i32` or `i64` depending on the pointer
+// width of the host
 //
 // `float32` - a wasm `f32`
 //
@@ -158,15 +188,28 @@ func goTrampolineNew(
 //
 // `*Func` - a wasm `funcref`
 //
-// anything else - a wasm `externref`
+// `interface{}` - a wasm `externref`
+//
+// Additionally the first argument may be a `context.Context`, which receives
+// the context given to the `Func.CallContext` that led to this host function
+// being invoked, or `context.Background()` if there isn't one.
+//
+// Unsigned integers are bit-cast to and from their signed wasm counterparts,
+// and a `bool` is passed as `0` or `1`. Any other type causes `WrapFunc` to
+// panic rather than silently picking a wasm type.
 //
 // The Go function may return any number of values. It can return any number of
-// primitive wasm values (integers/floats), and the last return value may
-// optionally be `*Trap`. If a `*Trap` returned is `nil` then the other values
-// are returned from the wasm function. Otherwise the `*Trap` is returned and
-// it's considered as if the host function trapped.
+// the above wasm values, and the last return value may optionally be `*Trap` or
+// `error`. If the `*Trap` or `error` returned is `nil` then the other values
+// are returned from the wasm function. Otherwise it's considered as if the host
+// function trapped, and a non-nil `error` is converted to a trap with
+// `NewTrapFromError`.
 //
-// If the function `f` panics then the panic will be propagated to the caller.
+// If the function `f` panics then the panic will be propagated to the caller,
+// as a `*HostPanic` carrying the original panic value and stack.
+//
+// As with `NewFunc`, the function `f` is retained by `store` until the store is
+// closed.
 func WrapFunc(
 	store Storelike,
 	f interface{},
@@ -179,7 +222,7 @@ func WrapFunc(
 	wasmtime_func_new(
 		uintptr(store.Context()),
 		wasmTy.ptr(),
-		purego.NewCallback(goTrampolineWrap),
+		goTrampolineWrapPtr,
 		idx,
 		0, // this is `WrapFunc`, not `NewFunc`
 		&ret,
@@ -199,21 +242,22 @@ func inferFuncType(val reflect.Value) *FuncType {
 	// infer the parameter types, and `*Caller` type is special in the
 	// parameters so be sure to case on that as well.
 	params := make([]*ValType, 0, ty.NumIn())
-	var caller *Caller
 	for i := 0; i < ty.NumIn(); i++ {
 		paramTy := ty.In(i)
-		if paramTy != reflect.TypeOf(caller) {
+		if i == 0 && paramTy == contextType {
+			continue
+		}
+		if paramTy != callerType {
 			params = append(params, typeToValType(paramTy))
 		}
 	}
 
-	// Then infer the result types, where a final `*Trap` result value is
-	// also special.
+	// Then infer the result types, where a final `*Trap` or `error` result
+	// value is also special.
 	results := make([]*ValType, 0, ty.NumOut())
-	var trap *Trap
 	for i := 0; i < ty.NumOut(); i++ {
 		resultTy := ty.Out(i)
-		if i == ty.NumOut()-1 && resultTy == reflect.TypeOf(trap) {
+		if i == ty.NumOut()-1 && (resultTy == trapType || resultTy == errorType) {
 			continue
 		}
 		results = append(results, typeToValType(resultTy))
@@ -221,34 +265,106 @@ func inferFuncType(val reflect.Value) *FuncType {
 	return NewFuncType(params, results)
 }
 
+var (
+	callerType    = reflect.TypeOf((*Caller)(nil))
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	funcType      = reflect.TypeOf((*Func)(nil))
+	trapType      = reflect.TypeOf((*Trap)(nil))
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	externrefType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
 func typeToValType(ty reflect.Type) *ValType {
-	var a int32
-	if ty == reflect.TypeOf(a) {
-		return NewValType(KindI32)
+	kind, ok := typeToValKind(ty)
+	if !ok {
+		panic(fmt.Sprintf("unsupported type `%s` in wrapped function signature", ty))
 	}
-	var b int64
-	if ty == reflect.TypeOf(b) {
-		return NewValType(KindI64)
+	return NewValType(kind)
+}
+
+// typeToValKind returns the wasm kind used to represent values of the Go type
+// `ty` in a `WrapFunc` signature, or false if the type isn't supported.
+func typeToValKind(ty reflect.Type) (ValKind, bool) {
+	switch ty {
+	case funcType:
+		return KindFuncref, true
+	case externrefType:
+		return KindExternref, true
 	}
-	var c float32
-	if ty == reflect.TypeOf(c) {
-		return NewValType(KindF32)
+	switch ty.Kind() {
+	case reflect.Int32, reflect.Uint32, reflect.Bool:
+		return KindI32, true
+	case reflect.Int64, reflect.Uint64:
+		return KindI64, true
+	case reflect.Int, reflect.Uint, reflect.Uintptr:
+		// These follow the pointer width of the host.
+		if unsafe.Sizeof(uintptr(0)) == 4 {
+			return KindI32, true
+		}
+		return KindI64, true
+	case reflect.Float32:
+		return KindF32, true
+	case reflect.Float64:
+		return KindF64, true
 	}
-	var d float64
-	if ty == reflect.TypeOf(d) {
-		return NewValType(KindF64)
+	return 0, false
+}
+
+// valToGo converts the wasm value `val` to a Go value of type `ty`, the
+// inverse of `goToVal`.
+func valToGo(val Val, ty reflect.Type) reflect.Value {
+	switch ty.Kind() {
+	case reflect.Bool:
+		return reflect.ValueOf(val.I32() != 0).Convert(ty)
+	case reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64,
+		reflect.Int, reflect.Uint, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(val.Get()).Convert(ty)
+	}
+	if val.Get() == nil {
+		return reflect.Zero(ty)
+	}
+	return reflect.ValueOf(val.Get())
+}
+
+// goToVal converts a Go value returned from a wrapped function to the wasm
+// value it's represented as, according to `typeToValKind`.
+func goToVal(val reflect.Value) Val {
+	kind, _ := typeToValKind(val.Type())
+	switch val.Kind() {
+	case reflect.Bool:
+		if val.Bool() {
+			return ValI32(1)
+		}
+		return ValI32(0)
+	case reflect.Int32, reflect.Int64, reflect.Int:
+		if kind == KindI32 {
+			return ValI32(int32(val.Int()))
+		}
+		return ValI64(val.Int())
+	case reflect.Uint32, reflect.Uint64, reflect.Uint, reflect.Uintptr:
+		if kind == KindI32 {
+			return ValI32(int32(val.Uint()))
+		}
+		return ValI64(int64(val.Uint()))
+	case reflect.Float32:
+		return ValF32(float32(val.Float()))
+	case reflect.Float64:
+		return ValF64(val.Float())
 	}
-	var f *Func
-	if ty == reflect.TypeOf(f) {
-		return NewValType(KindFuncref)
+	if kind == KindFuncref {
+		return ValFuncref(val.Interface().(*Func))
 	}
-	return NewValType(KindExternref)
+	return ValExternref(val.Interface())
 }
 
+// goTrampolineWrap is the `wasmtime_func_callback_t` of functions created with
+// `WrapFunc`, with the same arguments as `goTrampolineNew`.
+//
 //export goTrampolineWrap
 func goTrampolineWrap(
-	callerPtr uintptr,
 	env int,
+	callerPtr uintptr,
 	argsPtr uintptr,
 	argsNum int,
 	resultsPtr uintptr,
@@ -265,12 +381,14 @@ func goTrampolineWrap(
 	base := unsafe.Pointer(argsPtr)
 	var raw wasmtime_val_t
 	for i := 0; i < len(params); i++ {
-		if ty.In(i) == reflect.TypeOf(caller) {
+		if i == 0 && ty.In(i) == contextType {
+			params[i] = reflect.ValueOf(data.context())
+		} else if ty.In(i) == callerType {
 			params[i] = reflect.ValueOf(caller)
 		} else {
 			ptr := (*wasmtime_val_t)(base)
 			val := mkVal(caller, ptr)
-			params[i] = reflect.ValueOf(val.Get())
+			params[i] = valToGo(val, ty.In(i))
 			base = unsafe.Pointer(uintptr(base) + unsafe.Sizeof(raw))
 		}
 	}
@@ -278,16 +396,38 @@ func goTrampolineWrap(
 	// Invoke the function, catching any panics to propagate later. Panics
 	// result in immediately returning a trap.
 	var results []reflect.Value
-	var lastPanic interface{}
+	var lastPanic *HostPanic
 	func() {
-		defer func() { lastPanic = recover() }()
+		defer recoverHostPanic(&lastPanic)
 		results = entry.callback.Call(params)
 	}()
 	if lastPanic != nil {
-		data.lastPanic = lastPanic
-		trap := NewTrap("go panicked")
-		runtime.SetFinalizer(trap, nil)
-		return uintptr(trap.ptr())
+		return data.panicTrap(lastPanic)
+	}
+
+	// A trailing `*Trap` or `error` takes precedence over the other results,
+	// so handle it first.
+	if n := len(results); n > 0 {
+		switch ty.Out(n - 1) {
+		case trapType:
+			trap := results[n-1].Interface().(*Trap)
+			results = results[:n-1]
+			if trap != nil {
+				if trap._ptr == nil {
+					return data.panicTrap(&HostPanic{
+						Value: "cannot return trap twice",
+						Stack: debug.Stack(),
+					})
+				}
+				return data.returnTrap(trap)
+			}
+		case errorType:
+			err, _ := results[n-1].Interface().(error)
+			results = results[:n-1]
+			if err != nil {
+				return data.returnTrap(NewTrapFromError(err))
+			}
+		}
 	}
 
 	// And now we write all the results into memory depending on the type
@@ -295,35 +435,211 @@ func goTrampolineWrap(
 	base = unsafe.Pointer(resultsPtr)
 	for _, result := range results {
 		ptr := (*wasmtime_val_t)(base)
-		switch val := result.Interface().(type) {
+		goToVal(result).initialize(caller, ptr)
+		base = unsafe.Pointer(uintptr(base) + unsafe.Sizeof(raw))
+	}
+	return uintptr(0)
+}
+
+// Type returns the type of this func
+func (f *Func) Type(store Storelike) *FuncType {
+	ptr := wasmtime_func_type(uintptr(store.Context()), f.ptr())
+	runtime.KeepAlive(store)
+	return mkFuncType(ptr, nil)
+}
+
+// Call invokes this function with the provided `args`.
+//
+// This variadic function must be invoked with the correct number and type of
+// `args` as specified by the type of this function. This property is checked
+// at runtime. Each `args` may have one of the following types:
+//
+// `int32` - a wasm `i32`
+//
+// `int64` - a wasm `i64`
+//
+// `float32` - a wasm `f32`
+//
+// `float64` - a wasm `f64`
+//
+// `Val` - correspond to a wasm value
+//
+// `*Func` - a wasm `funcref`
+//
+// anything else - a wasm `externref`
+//
+// This function will have one of three results:
+//
+// 1. If the function returns successfully, then the `interface{}` return
+// argument will be the result of the function. If there were 0 results then
+// this value is `nil`. If there was one result then this is that result.
+// Otherwise if there were multiple results then `[]Val` is returned.
+//
+// 2. If this function invocation traps, then the returned `interface{}` value
+// will be `nil` and a non-`nil` `*Trap` will be returned with information
+// about the trap that happened.
+//
+// 3. If the function invocation fails for any other reason, such as the
+// arguments not matching the function's type, then an error is returned.
+//
+// Host functions invoked during this call which take a `context.Context`
+// receive the same context as the `Func.CallContext` that's currently
+// executing in `store`, if any.
+func (f *Func) Call(store Storelike, args ...interface{}) (interface{}, error) {
+	ty := f.Type(store)
+	params := ty.Params()
+	if len(args) > len(params) {
+		return nil, errors.New("too many arguments provided")
+	}
+	paramsVec := make([]wasmtime_val_t, len(args))
+	// The `externref` arguments are rooted in the store until they're
+	// unrooted here, after which wasmtime keeps them alive only as long as
+	// wasm still references them.
+	defer func() {
+		for i := range paramsVec {
+			wasmtime_val_unroot(uintptr(store.Context()), uintptr(unsafe.Pointer(&paramsVec[i])))
+		}
+		runtime.KeepAlive(store)
+	}()
+	for i, param := range args {
+		dst := &paramsVec[i]
+		var val Val
+		switch param := param.(type) {
+		case int:
+			switch params[i].Kind() {
+			case KindI32:
+				val = ValI32(int32(param))
+			case KindI64:
+				val = ValI64(int64(param))
+			default:
+				return nil, errors.New("integer provided for non-integer argument")
+			}
 		case int32:
-			ValI32(val).initialize(caller, ptr)
+			val = ValI32(param)
 		case int64:
-			ValI64(val).initialize(caller, ptr)
+			val = ValI64(param)
 		case float32:
-			ValF32(val).initialize(caller, ptr)
+			val = ValF32(param)
 		case float64:
-			ValF64(val).initialize(caller, ptr)
+			val = ValF64(param)
 		case *Func:
-			ValFuncref(val).initialize(caller, ptr)
-		case *Trap:
-			if val != nil {
-				runtime.SetFinalizer(val, nil)
-				ret := val._ptr
-				val._ptr = nil
-				if ret == nil {
-					data.lastPanic = "cannot return trap twice"
-					return uintptr(0)
-				} else {
-					return uintptr(ret)
-				}
-			}
+			val = ValFuncref(param)
+		case Val:
+			val = param
 		default:
-			ValExternref(val).initialize(caller, ptr)
+			val = ValExternref(param)
 		}
-		base = unsafe.Pointer(uintptr(base) + unsafe.Sizeof(raw))
+		if val.Kind() != params[i].Kind() {
+			return nil, fmt.Errorf("argument %d is a `%s` but the function expects a `%s`", i, val.Kind(), params[i].Kind())
+		}
+		val.initialize(store, dst)
 	}
-	return uintptr(0)
+
+	resultsVec := make([]wasmtime_val_t, len(ty.Results()))
+
+	err := enterWasm(store, func(trap *uintptr) uintptr {
+		var paramsPtr, resultsPtr *wasmtime_val_t
+		if len(paramsVec) > 0 {
+			paramsPtr = &paramsVec[0]
+		}
+		if len(resultsVec) > 0 {
+			resultsPtr = &resultsVec[0]
+		}
+		return wasmtime_func_call(
+			uintptr(store.Context()),
+			f.ptr(),
+			paramsPtr,
+			len(paramsVec),
+			resultsPtr,
+			len(resultsVec),
+			trap,
+		)
+	})
+	runtime.KeepAlive(args)
+	runtime.KeepAlive(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resultsVec) == 0 {
+		return nil, nil
+	}
+
+	if len(resultsVec) == 1 {
+		ret := takeVal(store, &resultsVec[0])
+		return ret.Get(), nil
+	}
+
+	results := make([]Val, len(resultsVec))
+	for i := 0; i < len(results); i++ {
+		results[i] = takeVal(store, &resultsVec[i])
+	}
+	return results, nil
+}
+
+// CallContext is the same as `Call` except that `ctx` is handed to any host
+// function taking a `context.Context` which is invoked while this call is
+// executing.
+//
+// The context of an outer `CallContext` in the same store is restored once
+// this call returns.
+func (f *Func) CallContext(ctx context.Context, store Storelike, args ...interface{}) (interface{}, error) {
+	data := getDataInStore(store)
+	prev := data.ctx
+	data.ctx = ctx
+	defer func() { data.ctx = prev }()
+	return f.Call(store, args...)
+}
+
+// enterWasm invokes `run`, which is expected to call into wasm, and translates
+// the returned `*wasmtime_error_t` or `*wasm_trap_t` into a Go error.
+//
+// If wasm was unwound by a host function panicking then the panic is raised
+// again here as a `*HostPanic`, unless the store is configured to return it as
+// a trap instead.
+func enterWasm(store Storelike, run func(trap *uintptr) uintptr) error {
+	var trap uintptr
+	err := run(&trap)
+	runtime.KeepAlive(store)
+	data := getDataInStore(store)
+	cause := data.lastTrapCause
+	data.lastTrapCause = nil
+	if trap != 0 || data.lastPanic != nil {
+		data.trapped = true
+	}
+	if lastPanic := data.lastPanic; lastPanic != nil && (err != 0 || trap != 0) {
+		data.lastPanic = nil
+		if data.trapOnPanic && trap != 0 {
+			ret := mkTrap((*wasm_trap_t)(unsafe.Pointer(trap)))
+			ret.cause = lastPanic
+			return ret
+		}
+		if err != 0 {
+			wasmtime_error_delete(err)
+		}
+		if trap != 0 {
+			wasm_trap_delete(trap)
+		}
+		panic(lastPanic)
+	}
+	if err != 0 {
+		return mkError(unsafe.Pointer(err))
+	}
+	if trap != 0 {
+		ret := mkTrap((*wasm_trap_t)(unsafe.Pointer(trap)))
+		ret.cause = cause
+		return ret
+	}
+	return nil
+}
+
+func (f *Func) ptr() *wasmtime_func_t {
+	ret := f.val
+	if ret == nil {
+		panic("object has been closed already")
+	}
+	return (*wasmtime_func_t)(ret)
 }
 
 func mkFunc(val *wasmtime_func_t) *Func {
@@ -337,3 +653,40 @@ func (c *Caller) Context() unsafe.Pointer {
 	}
 	return unsafe.Pointer(wasmtime_caller_context(uintptr(c.ptr)))
 }
+
+// GetExport gets an exported item from the caller's module.
+//
+// May return `nil` if the export doesn't exist, if it's not a memory, if there
+// isn't a caller, etc.
+func (c *Caller) GetExport(name string) *Extern {
+	if c.ptr == nil {
+		panic("cannot use caller after host function returns")
+	}
+	var ret wasmtime_extern_t
+	ok := wasmtime_caller_export_get(uintptr(c.ptr), name, len(name), &ret)
+	runtime.KeepAlive(name)
+	if ok {
+		return mkExtern(&ret)
+	}
+	return nil
+}
+
+// Memory is a shorthand for `GetExport(name).Memory()`, returning `nil` if
+// there's no export named `name` or it isn't a memory.
+func (c *Caller) Memory(name string) *Memory {
+	export := c.GetExport(name)
+	if export == nil {
+		return nil
+	}
+	return export.Memory()
+}
+
+// Func is a shorthand for `GetExport(name).Func()`, returning `nil` if there's
+// no export named `name` or it isn't a function.
+func (c *Caller) Func(name string) *Func {
+	export := c.GetExport(name)
+	if export == nil {
+		return nil
+	}
+	return export.Func()
+}
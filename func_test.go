@@ -1,7 +1,12 @@
 package wasmtime
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestFunc(t *testing.T) {
@@ -21,3 +26,248 @@ func TestWrapFunc(t *testing.T) {
 		}
 	})
 }
+
+func TestWrapFuncTypes(t *testing.T) {
+	store := NewStore(NewEngine())
+	f := WrapFunc(store, func(a uint32, b bool, c uint64, d float32) (int64, error) {
+		return 0, nil
+	})
+	require.NotNil(t, f)
+
+	require.Panics(t, func() {
+		WrapFunc(store, func(s string) {})
+	})
+	require.Panics(t, func() {
+		WrapFunc(store, func() []byte { return nil })
+	})
+}
+
+func TestTypeToValKind(t *testing.T) {
+	for _, tc := range []struct {
+		val  interface{}
+		kind ValKind
+	}{
+		{int32(0), KindI32},
+		{uint32(0), KindI32},
+		{false, KindI32},
+		{int64(0), KindI64},
+		{uint64(0), KindI64},
+		{float32(0), KindF32},
+		{float64(0), KindF64},
+		{(*Func)(nil), KindFuncref},
+	} {
+		kind, ok := typeToValKind(reflect.TypeOf(tc.val))
+		require.True(t, ok)
+		require.Equal(t, tc.kind, kind)
+	}
+
+	kind, ok := typeToValKind(externrefType)
+	require.True(t, ok)
+	require.Equal(t, KindExternref, kind)
+
+	_, ok = typeToValKind(reflect.TypeOf(""))
+	require.False(t, ok)
+	_, ok = typeToValKind(reflect.TypeOf(struct{}{}))
+	require.False(t, ok)
+}
+
+func TestGoToVal(t *testing.T) {
+	require.Equal(t, int32(-1), goToVal(reflect.ValueOf(uint32(0xffffffff))).I32())
+	require.Equal(t, int64(-1), goToVal(reflect.ValueOf(uint64(0xffffffffffffffff))).I64())
+	require.Equal(t, int32(1), goToVal(reflect.ValueOf(true)).I32())
+	require.Equal(t, int32(0), goToVal(reflect.ValueOf(false)).I32())
+
+	require.Equal(t, uint32(0xffffffff), valToGo(ValI32(-1), reflect.TypeOf(uint32(0))).Interface())
+	require.Equal(t, true, valToGo(ValI32(2), reflect.TypeOf(false)).Interface())
+}
+
+func TestFuncCall(t *testing.T) {
+	store := NewStore(NewEngine())
+	f := WrapFunc(store, func(a, b int32) int32 {
+		return a + b
+	})
+	ret, err := f.Call(store, 1, int32(2))
+	require.NoError(t, err)
+	require.Equal(t, int32(3), ret)
+
+	_, err = f.Call(store, 1, 2, 3)
+	require.Error(t, err)
+	_, err = f.Call(store, 1, float32(2))
+	require.Error(t, err)
+}
+
+type ctxKey struct{}
+
+func TestFuncCallContext(t *testing.T) {
+	store := NewStore(NewEngine())
+	ctx := context.WithValue(context.Background(), ctxKey{}, int32(7))
+
+	wrapped := WrapFunc(store, func(ctx context.Context, a int32) int32 {
+		return ctx.Value(ctxKey{}).(int32) + a
+	})
+	ret, err := wrapped.CallContext(ctx, store, int32(1))
+	require.NoError(t, err)
+	require.Equal(t, int32(8), ret)
+
+	i32 := NewValType(KindI32)
+	ty := NewFuncType([]*ValType{}, []*ValType{i32})
+	var seen context.Context
+	f := NewFuncContext(store, ty, func(ctx context.Context, caller *Caller, args []Val) ([]Val, *Trap) {
+		seen = ctx
+		return []Val{ValI32(0)}, nil
+	})
+	_, err = f.CallContext(ctx, store)
+	require.NoError(t, err)
+	require.Equal(t, ctx, seen)
+
+	_, err = f.Call(store)
+	require.NoError(t, err)
+	require.Equal(t, context.Background(), seen)
+}
+
+func TestFuncExternref(t *testing.T) {
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "" "id" (func $id (param externref) (result externref)))
+	    (global $g (mut externref) (ref.null extern))
+	    (func (export "set") (param externref)
+	      (global.set $g (local.get 0)))
+	    (func (export "get") (result externref)
+	      (global.get $g))
+	    (func (export "roundtrip") (param externref) (result externref)
+	      (call $id (local.get 0)))
+	  )
+	`)
+	require.NoError(t, err)
+	store := NewStore(NewEngine())
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+
+	var seen []interface{}
+	id := WrapFunc(store, func(x interface{}) interface{} {
+		seen = append(seen, x)
+		return x
+	})
+	instance, err := NewInstance(store, module, []AsExtern{id})
+	require.NoError(t, err)
+	set := instance.GetFunc(store, "set")
+	get := instance.GetFunc(store, "get")
+	roundtrip := instance.GetFunc(store, "roundtrip")
+
+	ret, err := get.Call(store)
+	require.NoError(t, err)
+	require.Nil(t, ret)
+
+	// The value kept by wasm is the same Go value, not a copy.
+	type data struct{ n int }
+	val := &data{n: 1}
+	_, err = set.Call(store, val)
+	require.NoError(t, err)
+	ret, err = get.Call(store)
+	require.NoError(t, err)
+	require.Same(t, val, ret)
+
+	ret, err = roundtrip.Call(store, "hello")
+	require.NoError(t, err)
+	require.Equal(t, "hello", ret)
+	ret, err = roundtrip.Call(store, ValExternref(nil))
+	require.NoError(t, err)
+	require.Nil(t, ret)
+	require.Equal(t, []interface{}{"hello", nil}, seen)
+}
+
+func TestCallerGetExport(t *testing.T) {
+	store := NewStore(NewEngine())
+	// There's no calling instance when a host function is invoked directly
+	// from Go, so no exports are available.
+	var export *Extern
+	var mem *Memory
+	var alloc *Func
+	f := WrapFunc(store, func(caller *Caller) {
+		export = caller.GetExport("memory")
+		mem = caller.Memory("memory")
+		alloc = caller.Func("alloc")
+	})
+	_, err := f.Call(store)
+	require.NoError(t, err)
+	require.Nil(t, export)
+	require.Nil(t, mem)
+	require.Nil(t, alloc)
+}
+
+func TestCallerGetExportInstance(t *testing.T) {
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "" "log" (func $log (param i32 i32) (result i32)))
+	    (memory (export "memory") 1)
+	    (data (i32.const 16) "hello")
+	    (func (export "double") (param i32) (result i32)
+	      (i32.mul (local.get 0) (i32.const 2)))
+	    (func (export "run") (result i32)
+	      (call $log (i32.const 16) (i32.const 5)))
+	  )
+	`)
+	require.NoError(t, err)
+	store := NewStore(NewEngine())
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+
+	// Assertions in the host function would stop the goroutine with wasm
+	// frames on its stack, so its observations are checked after the call.
+	var export, missing *Extern
+	var memoryFunc *Func
+	var doubleMemory *Memory
+	var logged string
+	log := WrapFunc(store, func(caller *Caller, ptr, size int32) (int32, error) {
+		export = caller.GetExport("memory")
+		missing = caller.GetExport("missing")
+		memoryFunc = caller.Func("memory")
+		doubleMemory = caller.Memory("double")
+
+		mem := caller.Memory("memory")
+		if mem == nil {
+			return 0, errors.New("no memory export")
+		}
+		logged = string(mem.UnsafeData(caller)[ptr : ptr+size])
+
+		double := caller.Func("double")
+		if double == nil {
+			return 0, errors.New("no double export")
+		}
+		ret, err := double.Call(caller, int32(21))
+		if err != nil {
+			return 0, err
+		}
+		return ret.(int32), nil
+	})
+	instance, err := NewInstance(store, module, []AsExtern{log})
+	require.NoError(t, err)
+
+	ret, err := instance.GetFunc(store, "run").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(42), ret)
+	require.Equal(t, "hello", logged)
+	require.NotNil(t, export)
+	require.Nil(t, missing)
+	require.Nil(t, memoryFunc)
+	require.Nil(t, doubleMemory)
+}
+
+// BenchmarkHostCallParallel calls a host function from many goroutines, each
+// with its own store. Run it with `-cpu 1,2,4,8` to see throughput scaling
+// with GOMAXPROCS.
+func BenchmarkHostCallParallel(b *testing.B) {
+	engine := NewEngine()
+	b.RunParallel(func(pb *testing.PB) {
+		store := NewStore(engine)
+		defer store.Close()
+		f := WrapFunc(store, func(a int32) int32 {
+			return a + 1
+		})
+		for pb.Next() {
+			if _, err := f.Call(store, int32(1)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -24,6 +24,7 @@ type FuncType struct {
 
 // NewFuncType creates a new `FuncType` with the `kind` provided
 func NewFuncType(params, results []*ValType) *FuncType {
+	mustLoad()
 	paramVec := mkValTypeList(params)
 	resultVec := mkValTypeList(results)
 
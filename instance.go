@@ -0,0 +1,115 @@
+package wasmtime
+
+import (
+	"runtime"
+)
+
+type wasmtime_instance_t struct {
+	/// Internal identifier of what store this belongs to, never zero.
+	store_id uint64
+	/// Internal index within the store.
+	index uintptr
+}
+
+// Instance is an instantiated module instance.
+// Once a module has been instantiated as an Instance, any exported function can be invoked externally via its function address funcaddr in the store S and an appropriate list val∗ of argument values.
+type Instance struct {
+	val wasmtime_instance_t
+}
+
+// AsExtern is an interface for all types which can be imported or exported as an Extern
+type AsExtern interface {
+	AsExtern() wasmtime_extern_t
+}
+
+// NewInstance instantiates a WebAssembly `module` with the `imports` provided.
+//
+// This function will attempt to create a new wasm instance given the provided
+// imports. This can fail if the wrong number of imports are specified, the
+// imports aren't of the right type, or for other resource-related issues.
+//
+// This will also run the `start` function of the instance, returning an error
+// if it traps.
+func NewInstance(store Storelike, module *Module, imports []AsExtern) (*Instance, error) {
+	importsRaw := make([]wasmtime_extern_t, len(imports))
+	for i, imp := range imports {
+		importsRaw[i] = imp.AsExtern()
+	}
+	var ret wasmtime_instance_t
+	err := enterWasm(store, func(trap *uintptr) uintptr {
+		var importsPtr *wasmtime_extern_t
+		if len(importsRaw) > 0 {
+			importsPtr = &importsRaw[0]
+		}
+		return wasmtime_instance_new(
+			uintptr(store.Context()),
+			uintptr(module.ptr()),
+			importsPtr,
+			len(importsRaw),
+			&ret,
+			trap,
+		)
+	})
+	runtime.KeepAlive(module)
+	runtime.KeepAlive(imports)
+	runtime.KeepAlive(importsRaw)
+	if err != nil {
+		return nil, err
+	}
+	return mkInstance(ret), nil
+}
+
+func mkInstance(val wasmtime_instance_t) *Instance {
+	return &Instance{val: val}
+}
+
+// GetExport attempts to find an export on this instance by `name`
+//
+// May return `nil` if this instance has no export named `name`
+func (i *Instance) GetExport(store Storelike, name string) *Extern {
+	var item wasmtime_extern_t
+	ok := wasmtime_instance_export_get(uintptr(store.Context()), &i.val, name, len(name), &item)
+	runtime.KeepAlive(store)
+	runtime.KeepAlive(name)
+	if ok {
+		return mkExtern(&item)
+	}
+	return nil
+}
+
+// GetFunc attempts to find a function on this instance by `name`.
+//
+// May return `nil` if this instance has no function named `name`,
+// it is not a function, etc.
+func (i *Instance) GetFunc(store Storelike, name string) *Func {
+	f := i.GetExport(store, name)
+	if f == nil {
+		return nil
+	}
+	return f.Func()
+}
+
+// AsExtern implements the `AsExtern` interface for `*Func`.
+func (f *Func) AsExtern() wasmtime_extern_t {
+	var ret wasmtime_extern_t
+	ret.kind = externKindFunc
+	*ret.funcPtr() = *f.ptr()
+	return ret
+}
+
+// AsExtern implements the `AsExtern` interface for `*Memory`.
+func (mem *Memory) AsExtern() wasmtime_extern_t {
+	var ret wasmtime_extern_t
+	ret.kind = externKindMemory
+	*ret.memoryPtr() = mem.val
+	return ret
+}
+
+// AsExtern implements the `AsExtern` interface for `*Extern`.
+func (e *Extern) AsExtern() wasmtime_extern_t {
+	return e.val
+}
+
+var _ AsExtern = (*Func)(nil)
+var _ AsExtern = (*Memory)(nil)
+var _ AsExtern = (*Extern)(nil)
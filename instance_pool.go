@@ -0,0 +1,273 @@
+package wasmtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// InstancePool keeps instances of an `InstancePre` warm, each in a store of
+// its own, and hands them out to one goroutine at a time.
+//
+// An instance which traps, or whose host function panics, is discarded when
+// it's returned rather than handed out again, since wasm may have left it in
+// an inconsistent state. A fresh instance takes its place the next time one
+// is needed.
+//
+// An InstancePool may be used from multiple goroutines at once.
+type InstancePool struct {
+	pre    *InstancePre
+	stores *StorePool
+	size   int
+	// Holds `size` entries, with nil standing for a slot whose instance
+	// hasn't been created yet or was discarded.
+	slots chan *PooledInstance
+	done  chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	stats  InstancePoolStats
+}
+
+// PooledInstance is an instance handed out by an `InstancePool`.
+type PooledInstance struct {
+	Store    *Store
+	Instance *Instance
+	pool     *InstancePool
+	// Whether this is handed out, rather than waiting in the pool or
+	// deleted. Guarded by the pool's `mu`.
+	checkedOut bool
+}
+
+// InstancePoolStats reports the state of an `InstancePool`.
+type InstancePoolStats struct {
+	// The most instances the pool holds.
+	Size int
+	// The instances waiting in the pool to be handed out.
+	Idle int
+	// The instances handed out and not yet returned.
+	InUse int
+	// The total number of instances created, and of those discarded.
+	Created   uint64
+	Discarded uint64
+	// The number of calls to `Get` which had to wait for an instance to be
+	// returned.
+	Waits uint64
+}
+
+// NewInstancePool creates a pool of up to `size` instances of `pre`, each in
+// a store configured by `opts`. The instances are created up front, so that
+// an error instantiating the module is returned here.
+//
+// Fuel and the epoch deadline are reapplied each time an instance is handed
+// out.
+func NewInstancePool(pre *InstancePre, size int, opts StoreOptions) (*InstancePool, error) {
+	if size <= 0 {
+		return nil, errors.New("instance pool size must be positive")
+	}
+	p := &InstancePool{
+		pre:    pre,
+		stores: NewStorePool(pre.engine, opts),
+		size:   size,
+		slots:  make(chan *PooledInstance, size),
+		done:   make(chan struct{}),
+	}
+	p.stats.Size = size
+	for i := 0; i < size; i++ {
+		pi, err := p.instantiate()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.slots <- pi
+		p.stats.Idle++
+	}
+	return p, nil
+}
+
+// instantiate creates a new instance in a store of its own.
+func (p *InstancePool) instantiate() (*PooledInstance, error) {
+	store, err := p.stores.Get()
+	if err != nil {
+		return nil, err
+	}
+	instance, err := p.pre.Instantiate(store)
+	if err != nil {
+		p.stores.Put(store)
+		return nil, err
+	}
+	p.mu.Lock()
+	p.stats.Created++
+	p.mu.Unlock()
+	return &PooledInstance{Store: store, Instance: instance, pool: p}, nil
+}
+
+// Get hands out an instance, waiting for one to be returned if all of them
+// are in use. It must be given back with `Put` once it's no longer needed.
+//
+// Returns the context's error if it's done before an instance is available,
+// `ErrPoolClosed` if the pool is closed, or an error if a new instance had to
+// be created and that failed.
+func (p *InstancePool) Get(ctx context.Context) (*PooledInstance, error) {
+	var pi *PooledInstance
+	select {
+	case pi = <-p.slots:
+	default:
+		p.mu.Lock()
+		p.stats.Waits++
+		p.mu.Unlock()
+		select {
+		case pi = <-p.slots:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.done:
+			return nil, ErrPoolClosed
+		}
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.release(pi)
+		return nil, ErrPoolClosed
+	}
+	if pi != nil {
+		p.stats.Idle--
+		pi.checkedOut = true
+	}
+	p.stats.InUse++
+	p.mu.Unlock()
+
+	if pi == nil {
+		var err error
+		if pi, err = p.instantiate(); err != nil {
+			p.refill(nil)
+			return nil, err
+		}
+		// Nothing else can see `pi` yet, so this needs no lock.
+		pi.checkedOut = true
+	} else if err := p.stores.configure(pi.Store); err != nil {
+		p.release(pi)
+		p.refill(nil)
+		return nil, err
+	}
+	return pi, nil
+}
+
+// Put returns an instance handed out by `Get` to the pool. Neither it nor its
+// store may be used afterwards.
+//
+// Returning an instance which has already been returned does nothing.
+func (p *InstancePool) Put(pi *PooledInstance) {
+	if !p.checkIn(pi) {
+		return
+	}
+	if getDataInStore(pi.Store).trapped {
+		p.discard(pi)
+		return
+	}
+	p.refill(pi)
+}
+
+// Discard returns an instance handed out by `Get` to the pool, which deletes
+// it rather than handing it out again, for example if its state is no longer
+// wanted.
+//
+// Returning an instance which has already been returned does nothing.
+func (p *InstancePool) Discard(pi *PooledInstance) {
+	if p.checkIn(pi) {
+		p.discard(pi)
+	}
+}
+
+// checkIn marks `pi` as no longer handed out, returning false if it already
+// wasn't.
+func (p *InstancePool) checkIn(pi *PooledInstance) bool {
+	if pi.pool != p {
+		panic("instance doesn't belong to this InstancePool")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !pi.checkedOut {
+		return false
+	}
+	pi.checkedOut = false
+	return true
+}
+
+// discard deletes `pi`, which has been checked in, and puts its slot back
+// into the pool.
+func (p *InstancePool) discard(pi *PooledInstance) {
+	p.release(pi)
+	p.mu.Lock()
+	p.stats.Discarded++
+	p.mu.Unlock()
+	p.refill(nil)
+}
+
+// refill puts a slot handed out by `Get` back into the pool, holding `pi` or
+// nil if there's no instance in it any more.
+func (p *InstancePool) refill(pi *PooledInstance) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.InUse--
+	if p.closed {
+		p.release(pi)
+		return
+	}
+	if pi != nil {
+		p.stats.Idle++
+	}
+	// Every slot is handed out by `Get` and checked in once, so there are
+	// never more than `size` of them and the channel always has room. The send
+	// mustn't block regardless, since `mu` is held.
+	select {
+	case p.slots <- pi:
+	default:
+		panic("InstancePool has more slots than its size")
+	}
+}
+
+// release deletes the store of `pi`, if any.
+func (p *InstancePool) release(pi *PooledInstance) {
+	if pi != nil {
+		p.stores.Put(pi.Store)
+	}
+}
+
+// Stats reports the current state of the pool.
+func (p *InstancePool) Stats() InstancePoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Close deletes the instances waiting in the pool, and makes `Get` return
+// `ErrPoolClosed`. Instances which are still handed out are deleted when
+// they're returned.
+func (p *InstancePool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.stats.Idle = 0
+	close(p.done)
+	var idle []*PooledInstance
+drain:
+	for {
+		select {
+		case pi := <-p.slots:
+			idle = append(idle, pi)
+		default:
+			break drain
+		}
+	}
+	p.mu.Unlock()
+
+	for _, pi := range idle {
+		p.release(pi)
+	}
+	p.stores.Close()
+}
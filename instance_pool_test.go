@@ -0,0 +1,168 @@
+package wasmtime
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestInstancePool(t *testing.T, size int) *InstancePool {
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "host" "add" (func $add (param i32 i32) (result i32)))
+	    (global $counter (mut i32) (i32.const 0))
+	    (func (export "next") (result i32)
+	      (global.set $counter (call $add (global.get $counter) (i32.const 1)))
+	      (global.get $counter))
+	    (func (export "trap") unreachable)
+	  )
+	`)
+	require.NoError(t, err)
+	engine := NewEngine()
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	require.NoError(t, linker.FuncWrap("host", "add", func(a, b int32) int32 { return a + b }))
+	pre, err := linker.InstantiatePre(module)
+	require.NoError(t, err)
+	pool, err := NewInstancePool(pre, size, StoreOptions{})
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func callNext(t *testing.T, pi *PooledInstance) int32 {
+	result, err := pi.Instance.GetFunc(pi.Store, "next").Call(pi.Store)
+	require.NoError(t, err)
+	return result.(int32)
+}
+
+func TestInstancePoolSize(t *testing.T) {
+	_, err := NewInstancePool(nil, 0, StoreOptions{})
+	require.Error(t, err)
+}
+
+func TestInstancePool(t *testing.T) {
+	pool := newTestInstancePool(t, 1)
+	require.Equal(t, InstancePoolStats{Size: 1, Idle: 1, Created: 1}, pool.Stats())
+
+	// Instances are reused, keeping their state.
+	pi, err := pool.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int32(1), callNext(t, pi))
+	require.Equal(t, InstancePoolStats{Size: 1, InUse: 1, Created: 1}, pool.Stats())
+	pool.Put(pi)
+	pi, err = pool.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int32(2), callNext(t, pi))
+
+	// Getting waits for an instance to be returned.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Get(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, uint64(1), pool.Stats().Waits)
+
+	// An instance which trapped is replaced by a fresh one.
+	_, err = pi.Instance.GetFunc(pi.Store, "trap").Call(pi.Store)
+	require.Error(t, err)
+	pool.Put(pi)
+	require.Equal(t, InstancePoolStats{Size: 1, Created: 1, Discarded: 1, Waits: 1}, pool.Stats())
+	pi, err = pool.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int32(1), callNext(t, pi))
+	require.Equal(t, uint64(2), pool.Stats().Created)
+
+	// Instances can be discarded explicitly too.
+	pool.Discard(pi)
+	require.Equal(t, uint64(2), pool.Stats().Discarded)
+	// Returning it again does nothing.
+	pool.Put(pi)
+	require.Equal(t, InstancePoolStats{Size: 1, Idle: 0, Created: 2, Discarded: 2, Waits: 1}, pool.Stats())
+}
+
+func TestInstancePoolDoublePut(t *testing.T) {
+	pool := newTestInstancePool(t, 2)
+	pi, err := pool.Get(context.Background())
+	require.NoError(t, err)
+	pool.Put(pi)
+	pool.Put(pi)
+	require.Equal(t, 2, pool.Stats().Idle)
+	require.Equal(t, 0, pool.Stats().InUse)
+
+	// The instance was only queued once, so two goroutines never share a
+	// store, and a third `Get` has to wait rather than getting it again.
+	a, err := pool.Get(context.Background())
+	require.NoError(t, err)
+	b, err := pool.Get(context.Background())
+	require.NoError(t, err)
+	require.NotSame(t, a.Store, b.Store)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Get(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	other := newTestInstancePool(t, 1)
+	require.Panics(t, func() { other.Put(a) })
+	pool.Put(a)
+	pool.Discard(b)
+	pool.Discard(b)
+	require.Equal(t, 1, pool.Stats().Idle)
+	require.Equal(t, uint64(1), pool.Stats().Discarded)
+}
+
+func TestInstancePoolConcurrent(t *testing.T) {
+	pool := newTestInstancePool(t, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				pi, err := pool.Get(context.Background())
+				if !assert.NoError(t, err) {
+					return
+				}
+				if j%10 == 0 {
+					pi.Instance.GetFunc(pi.Store, "trap").Call(pi.Store)
+				} else {
+					pi.Instance.GetFunc(pi.Store, "next").Call(pi.Store)
+				}
+				pool.Put(pi)
+			}
+		}()
+	}
+	wg.Wait()
+	stats := pool.Stats()
+	require.Equal(t, 0, stats.InUse)
+	require.Equal(t, uint64(40), stats.Discarded)
+	require.LessOrEqual(t, stats.Idle, 3)
+}
+
+func TestInstancePoolClose(t *testing.T) {
+	pool := newTestInstancePool(t, 2)
+	pi, err := pool.Get(context.Background())
+	require.NoError(t, err)
+
+	// Closing wakes up waiters.
+	other, err := pool.Get(context.Background())
+	require.NoError(t, err)
+	errs := make(chan error)
+	go func() {
+		_, err := pool.Get(context.Background())
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.Close()
+	require.ErrorIs(t, <-errs, ErrPoolClosed)
+
+	_, err = pool.Get(context.Background())
+	require.ErrorIs(t, err, ErrPoolClosed)
+	pool.Put(pi)
+	pool.Discard(other)
+	require.Equal(t, 0, pool.Stats().InUse)
+}
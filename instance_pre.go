@@ -0,0 +1,76 @@
+package wasmtime
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/hybridgroup/wasmtime/wasmparse"
+)
+
+// InstancePre is a module whose imports have been resolved by a `Linker`, so
+// that instantiating it only has to allocate the instance's state.
+//
+// An `InstancePre` may be used from multiple goroutines at once, each
+// instantiating it in a store of their own.
+type InstancePre struct {
+	_ptr unsafe.Pointer // *C.wasmtime_instance_pre_t
+	// The engine of the `Linker` this was created from.
+	engine *Engine
+	// The custom sections of the module, for `Module`.
+	customs []wasmparse.CustomSection
+}
+
+func mkInstancePre(ptr unsafe.Pointer, engine *Engine, module *Module) *InstancePre {
+	pre := &InstancePre{_ptr: ptr, engine: engine, customs: module.customs}
+	runtime.SetFinalizer(pre, func(pre *InstancePre) {
+		pre.Close()
+	})
+	return pre
+}
+
+func (p *InstancePre) ptr() uintptr {
+	ret := p._ptr
+	if ret == nil {
+		panic("object has been closed already")
+	}
+	//maybeGC()
+	return uintptr(ret)
+}
+
+// Close will deallocate this `InstancePre`'s state explicitly.
+//
+// For more information see the documentation for engine.Close()
+func (p *InstancePre) Close() {
+	if p._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(p, nil)
+	wasmtime_instance_pre_delete(uintptr(p._ptr))
+	p._ptr = nil
+}
+
+// Instantiate creates a new instance of the module in `store`, which must use
+// the same engine as the `Linker` this was created from.
+//
+// Returns an error if a trap happened executing the start function, or if
+// the instance couldn't be allocated.
+func (p *InstancePre) Instantiate(store Storelike) (*Instance, error) {
+	var ret wasmtime_instance_t
+	err := enterWasm(store, func(trap *uintptr) uintptr {
+		return wasmtime_instance_pre_instantiate(p.ptr(), uintptr(store.Context()), &ret, trap)
+	})
+	runtime.KeepAlive(p)
+	if err != nil {
+		return nil, err
+	}
+	return mkInstance(ret), nil
+}
+
+// Module returns the module this will instantiate.
+func (p *InstancePre) Module() *Module {
+	ptr := wasmtime_instance_pre_module(p.ptr())
+	runtime.KeepAlive(p)
+	module := mkModule(unsafe.Pointer(ptr))
+	module.customs = p.customs
+	return module
+}
@@ -0,0 +1,49 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance(t *testing.T) {
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "" "double" (func $double (param i32) (result i32)))
+	    (memory (export "memory") 1)
+	    (func (export "run") (param i32) (result i32)
+	      (call $double (local.get 0)))
+	  )
+	`)
+	require.NoError(t, err)
+	store := NewStore(NewEngine())
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+
+	double := WrapFunc(store, func(x int32) int32 { return x * 2 })
+	instance, err := NewInstance(store, module, []AsExtern{double})
+	require.NoError(t, err)
+
+	run := instance.GetFunc(store, "run")
+	require.NotNil(t, run)
+	result, err := run.Call(store, 21)
+	require.NoError(t, err)
+	require.Equal(t, int32(42), result)
+
+	require.NotNil(t, instance.GetExport(store, "memory").Memory())
+	require.Nil(t, instance.GetFunc(store, "memory"))
+	require.Nil(t, instance.GetExport(store, "missing"))
+
+	_, err = NewInstance(store, module, nil)
+	require.Error(t, err)
+}
+
+func TestInstanceStartTrap(t *testing.T) {
+	wasm, err := Wat2Wasm(`(module (func $start unreachable) (start $start))`)
+	require.NoError(t, err)
+	store := NewStore(NewEngine())
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	_, err = NewInstance(store, module, nil)
+	require.ErrorIs(t, err, UnreachableCodeReached)
+}
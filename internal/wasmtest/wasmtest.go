@@ -0,0 +1,57 @@
+// Package wasmtest encodes WebAssembly binaries by hand for tests, so that
+// they can exercise exactly the sections and encodings they need without
+// depending on a wat parser.
+package wasmtest
+
+// ULEB encodes `n` as an unsigned LEB128 integer.
+func ULEB(n uint64) []byte {
+	var b []byte
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+// Cat concatenates already encoded parts.
+func Cat(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+// Section encodes a section, or a subsection of the name section, with the
+// given contents.
+func Section(id byte, contents ...[]byte) []byte {
+	body := Cat(contents...)
+	return Cat([]byte{id}, ULEB(uint64(len(body))), body)
+}
+
+// Vec encodes a vector of already encoded items.
+func Vec(items ...[]byte) []byte {
+	return Cat(ULEB(uint64(len(items))), Cat(items...))
+}
+
+// Name encodes a name.
+func Name(s string) []byte {
+	return Cat(ULEB(uint64(len(s))), []byte(s))
+}
+
+// Module encodes a module with the given sections.
+func Module(sections ...[]byte) []byte {
+	return Cat([]byte("\x00asm\x01\x00\x00\x00"), Cat(sections...))
+}
+
+// Body encodes a function body with no locals.
+func Body(code ...byte) []byte {
+	contents := Cat([]byte{0}, code)
+	return Cat(ULEB(uint64(len(contents))), contents)
+}
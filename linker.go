@@ -0,0 +1,195 @@
+package wasmtime
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"unsafe"
+)
+
+// Linker implements a wasmtime Linking module, which can link instantiated modules together.
+// More details you can see [examples for C](https://bytecodealliance.github.io/wasmtime/examples-c-linking.html) or
+// [examples for Rust](https://bytecodealliance.github.io/wasmtime/examples-rust-linking.html)
+type Linker struct {
+	_ptr unsafe.Pointer // *C.wasmtime_linker_t
+
+	Engine *Engine
+}
+
+// NewLinker creates a new `Linker` for the `engine` provided.
+func NewLinker(engine *Engine) *Linker {
+	ptr := wasmtime_linker_new(uintptr(engine.ptr()))
+	runtime.KeepAlive(engine)
+	linker := &Linker{_ptr: unsafe.Pointer(ptr), Engine: engine}
+	runtime.SetFinalizer(linker, func(linker *Linker) {
+		linker.Close()
+	})
+	return linker
+}
+
+func (l *Linker) ptr() uintptr {
+	ret := l._ptr
+	if ret == nil {
+		panic("object has been closed already")
+	}
+	//maybeGC()
+	return uintptr(ret)
+}
+
+// Close will deallocate this linker's state explicitly.
+//
+// The functions defined with `FuncNew` and `FuncWrap` are released once
+// neither the linker nor any `InstancePre` created from it use them.
+//
+// For more information see the documentation for engine.Close()
+func (l *Linker) Close() {
+	if l._ptr == nil {
+		return
+	}
+	runtime.SetFinalizer(l, nil)
+	wasmtime_linker_delete(uintptr(l._ptr))
+	l._ptr = nil
+}
+
+// AllowShadowing configures whether names can be redefined after they've already been defined
+// in this linker.
+func (l *Linker) AllowShadowing(allow bool) {
+	wasmtime_linker_allow_shadowing(l.ptr(), allow)
+	runtime.KeepAlive(l)
+}
+
+// Define defines a new item in this linker with the given module/name pair. Returns
+// an error if shadowing is disallowed and the module/name is already defined.
+//
+// The `item` belongs to `store`, so the linker can then only instantiate
+// modules in that store.
+func (l *Linker) Define(store Storelike, module, name string, item AsExtern) error {
+	raw := item.AsExtern()
+	err := wasmtime_linker_define(l.ptr(), uintptr(store.Context()), module, len(module), name, len(name), &raw)
+	runtime.KeepAlive(l)
+	runtime.KeepAlive(store)
+	runtime.KeepAlive(item)
+	if err == 0 {
+		return nil
+	}
+	return mkError(unsafe.Pointer(err))
+}
+
+// FuncNew defines a function in this linker in the same style as `NewFunc`
+//
+// Note that this function does not require a `Storelike`, which is
+// intentional. This function can be used to insert store-independent functions
+// into this linker which allows this linker to be used for instantiating
+// modules in multiple different stores.
+//
+// Returns an error if shadowing is disabled and the name is already defined.
+func (l *Linker) FuncNew(module, name string, ty *FuncType, f func(*Caller, []Val) ([]Val, *Trap)) error {
+	return l.FuncNewContext(module, name, ty, func(_ context.Context, caller *Caller, args []Val) ([]Val, *Trap) {
+		return f(caller, args)
+	})
+}
+
+// FuncNewContext is the same as `FuncNew` except that `f` also receives a
+// `context.Context`, as with `NewFuncContext`.
+func (l *Linker) FuncNewContext(module, name string, ty *FuncType, f func(context.Context, *Caller, []Val) ([]Val, *Trap)) error {
+	idx := insertFuncNew(nil, ty, f)
+	err := wasmtime_linker_define_func(
+		l.ptr(),
+		module,
+		len(module),
+		name,
+		len(name),
+		ty.ptr(),
+		goTrampolineNewPtr,
+		idx,
+		goFinalizeFuncNewPtr,
+	)
+	runtime.KeepAlive(l)
+	runtime.KeepAlive(ty)
+	if err == 0 {
+		return nil
+	}
+	return mkError(unsafe.Pointer(err))
+}
+
+// FuncWrap defines a function in this linker in the same style as `WrapFunc`
+//
+// Note that this function does not require a `Storelike`, which is
+// intentional. This function can be used to insert store-independent functions
+// into this linker which allows this linker to be used for instantiating
+// modules in multiple different stores.
+//
+// Returns an error if shadowing is disabled and the name is already defined.
+func (l *Linker) FuncWrap(module, name string, f interface{}) error {
+	val := reflect.ValueOf(f)
+	wasmTy := inferFuncType(val)
+	idx := insertFuncWrap(nil, val)
+	err := wasmtime_linker_define_func(
+		l.ptr(),
+		module,
+		len(module),
+		name,
+		len(name),
+		wasmTy.ptr(),
+		goTrampolineWrapPtr,
+		idx,
+		goFinalizeFuncWrapPtr,
+	)
+	runtime.KeepAlive(l)
+	runtime.KeepAlive(wasmTy)
+	if err == 0 {
+		return nil
+	}
+	return mkError(unsafe.Pointer(err))
+}
+
+// Get loads an item by name from this linker.
+//
+// May return `nil` if nothing is defined as `module` and `name` in this
+// linker.
+func (l *Linker) Get(store Storelike, module, name string) *Extern {
+	var ret wasmtime_extern_t
+	ok := wasmtime_linker_get(l.ptr(), uintptr(store.Context()), module, len(module), name, len(name), &ret)
+	runtime.KeepAlive(l)
+	runtime.KeepAlive(store)
+	if ok {
+		return mkExtern(&ret)
+	}
+	return nil
+}
+
+// Instantiate instantiates a module with all imports defined in this linker.
+//
+// Returns an error if the instance's imports couldn't be satisfied, had the
+// wrong types, or if a trap happened executing the start function.
+func (l *Linker) Instantiate(store Storelike, module *Module) (*Instance, error) {
+	var ret wasmtime_instance_t
+	err := enterWasm(store, func(trap *uintptr) uintptr {
+		return wasmtime_linker_instantiate(l.ptr(), uintptr(store.Context()), uintptr(module.ptr()), &ret, trap)
+	})
+	runtime.KeepAlive(l)
+	runtime.KeepAlive(module)
+	if err != nil {
+		return nil, err
+	}
+	return mkInstance(ret), nil
+}
+
+// InstantiatePre resolves the imports of `module` against the definitions in
+// this linker once, returning an `InstancePre` which can then create instances
+// of it cheaply, for example one per request.
+//
+// All of the definitions used must be store-independent, so they have to be
+// defined with `FuncNew` or `FuncWrap` rather than `Define`.
+//
+// Later changes to this linker don't affect the returned `InstancePre`.
+func (l *Linker) InstantiatePre(module *Module) (*InstancePre, error) {
+	var ptr uintptr // *C.wasmtime_instance_pre_t
+	err := wasmtime_linker_instantiate_pre(l.ptr(), uintptr(module.ptr()), &ptr)
+	runtime.KeepAlive(l)
+	runtime.KeepAlive(module)
+	if err != 0 {
+		return nil, mkError(unsafe.Pointer(err))
+	}
+	return mkInstancePre(unsafe.Pointer(ptr), l.Engine, module), nil
+}
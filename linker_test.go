@@ -0,0 +1,90 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const linkerTestWat = `
+  (module
+    (import "host" "add" (func $add (param i32 i32) (result i32)))
+    (global $counter (mut i32) (i32.const 0))
+    (func (export "next") (result i32)
+      (global.set $counter (call $add (global.get $counter) (i32.const 1)))
+      (global.get $counter))
+  )
+`
+
+func TestLinker(t *testing.T) {
+	wasm, err := Wat2Wasm(linkerTestWat)
+	require.NoError(t, err)
+	engine := NewEngine()
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	linker := NewLinker(engine)
+	defer linker.Close()
+	require.NoError(t, linker.FuncWrap("host", "add", func(a, b int32) int32 { return a + b }))
+	require.Error(t, linker.FuncWrap("host", "add", func(a, b int32) int32 { return a + b }))
+	linker.AllowShadowing(true)
+	require.NoError(t, linker.FuncNew("host", "add",
+		NewFuncType([]*ValType{NewValType(KindI32), NewValType(KindI32)}, []*ValType{NewValType(KindI32)}),
+		func(caller *Caller, args []Val) ([]Val, *Trap) {
+			return []Val{ValI32(args[0].I32() + args[1].I32())}, nil
+		}))
+
+	store := NewStore(engine)
+	require.NotNil(t, linker.Get(store, "host", "add").Func())
+	require.Nil(t, linker.Get(store, "host", "missing"))
+
+	instance, err := linker.Instantiate(store, module)
+	require.NoError(t, err)
+	result, err := instance.GetFunc(store, "next").Call(store)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result)
+
+	// Items defined in a store can be linked too.
+	other, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	require.NoError(t, linker.Define(store, "other", "next", instance.GetExport(store, "next")))
+	require.NotNil(t, linker.Get(store, "other", "next"))
+	_, err = NewLinker(engine).Instantiate(store, other)
+	require.Error(t, err)
+}
+
+func TestInstancePre(t *testing.T) {
+	wasm, err := Wat2Wasm(linkerTestWat)
+	require.NoError(t, err)
+	engine := NewEngine()
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	linker := NewLinker(engine)
+	require.NoError(t, linker.FuncWrap("host", "add", func(a, b int32) int32 { return a + b }))
+	pre, err := linker.InstantiatePre(module)
+	require.NoError(t, err)
+	defer pre.Close()
+	// The resolved imports outlive the linker.
+	linker.Close()
+
+	// Every instance starts afresh, in its own store.
+	for i := 0; i < 100; i++ {
+		store := NewStore(engine)
+		instance, err := pre.Instantiate(store)
+		require.NoError(t, err)
+		next := instance.GetFunc(store, "next")
+		for want := int32(1); want <= 2; want++ {
+			result, err := next.Call(store)
+			require.NoError(t, err)
+			require.Equal(t, want, result)
+		}
+		store.Close()
+	}
+
+	_, err = pre.Module().Serialize()
+	require.NoError(t, err)
+
+	_, err = NewLinker(engine).InstantiatePre(module)
+	require.Error(t, err)
+}
@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ebitengine/purego"
 )
@@ -16,11 +18,50 @@ var libptr uintptr
 
 var wasm_engine_new func() uintptr
 var wasm_engine_delete func(ptr uintptr)
-var wasmtime_store_new func(ptr uintptr, idx int) uintptr
+var wasm_engine_new_with_config func(config uintptr) uintptr
+var wasm_config_new func() uintptr
+var wasm_config_delete func(config uintptr)
+var wasmtime_config_debug_info_set func(config uintptr, enabled bool)
+var wasmtime_config_wasm_reference_types_set func(config uintptr, enabled bool)
+var wasmtime_config_wasm_simd_set func(config uintptr, enabled bool)
+var wasmtime_config_wasm_relaxed_simd_set func(config uintptr, enabled bool)
+var wasmtime_config_wasm_bulk_memory_set func(config uintptr, enabled bool)
+var wasmtime_config_wasm_multi_value_set func(config uintptr, enabled bool)
+var wasmtime_config_wasm_multi_memory_set func(config uintptr, enabled bool)
+var wasmtime_config_wasm_memory64_set func(config uintptr, enabled bool)
+var wasmtime_config_wasm_tail_call_set func(config uintptr, enabled bool)
+var wasmtime_config_consume_fuel_set func(config uintptr, enabled bool)
+var wasmtime_config_epoch_interruption_set func(config uintptr, enabled bool)
+var wasmtime_config_max_wasm_stack_set func(config uintptr, size uintptr)
+var wasmtime_config_strategy_set func(config uintptr, strategy uint8)
+var wasmtime_config_cranelift_nan_canonicalization_set func(config uintptr, enabled bool)
+var wasmtime_config_cranelift_opt_level_set func(config uintptr, level uint8)
+var wasmtime_config_target_set func(config uintptr, target string) uintptr     // returns *wasmtime_error_t
+var wasmtime_config_cache_config_load func(config uintptr, path *byte) uintptr // returns *wasmtime_error_t
+var wasmtime_pooling_allocation_config_new func() uintptr
+var wasmtime_pooling_allocation_config_delete func(pool uintptr)
+var wasmtime_pooling_allocation_config_total_core_instances_set func(pool uintptr, n uint32)
+var wasmtime_pooling_allocation_config_total_memories_set func(pool uintptr, n uint32)
+var wasmtime_pooling_allocation_config_total_tables_set func(pool uintptr, n uint32)
+var wasmtime_pooling_allocation_config_max_memory_size_set func(pool uintptr, size uintptr)
+var wasmtime_pooling_allocation_config_max_core_instance_size_set func(pool uintptr, size uintptr)
+var wasmtime_pooling_allocation_config_max_memories_per_module_set func(pool uintptr, n uint32)
+var wasmtime_pooling_allocation_config_max_tables_per_module_set func(pool uintptr, n uint32)
+var wasmtime_pooling_allocation_config_table_elements_set func(pool uintptr, n uintptr)
+var wasmtime_pooling_allocation_config_max_unused_warm_slots_set func(pool uintptr, n uint32)
+var wasmtime_pooling_allocation_strategy_set func(config uintptr, pool uintptr)
+var wasmtime_store_new func(ptr uintptr, idx int, finalizer uintptr) uintptr
 var wasmtime_store_delete func(ptr uintptr)
+var wasmtime_store_limiter func(store uintptr, memorySize int64, tableElements int64, instances int64, tables int64, memories int64)
+var wasmtime_context_set_fuel func(context uintptr, fuel uint64) uintptr  // returns *wasmtime_error_t
+var wasmtime_context_get_fuel func(context uintptr, fuel *uint64) uintptr // returns *wasmtime_error_t
+var wasmtime_context_set_epoch_deadline func(context uintptr, ticksBeyondCurrent uint64)
+var wasmtime_engine_increment_epoch func(engine uintptr)
 var wasmtime_store_context func(ptr uintptr) uintptr // returns *wasmtime_context_t
 var wasmtime_module_new func(ptr uintptr, data []byte, size int, rtn *uintptr) uintptr
 var wasmtime_module_delete func(ptr uintptr)
+var wasmtime_module_serialize func(ptr uintptr, ret *wasm_byte_vec_t) uintptr
+var wasmtime_module_deserialize func(engine uintptr, data []byte, size int, ret *uintptr) uintptr
 var wasmtime_error_delete func(ptr uintptr)
 var wasmtime_wat2wasm func(wat string, size int, retVec *wasm_byte_vec_t) uintptr
 var wasm_byte_vec_delete func(vec *wasm_byte_vec_t)
@@ -29,8 +70,9 @@ var wasm_valtype_kind func(ptr *wasm_valtype_t) uint8
 var wasm_valtype_delete func(ptr *wasm_valtype_t)
 var wasm_functype_new func(params, results *wasm_valtype_vec_t) uintptr
 var wasm_valtype_vec_new_uninitialized func(vec *wasm_valtype_vec_t, size int) uintptr
-var wasm_functype_delete func(ptr uintptr)            // *wasm_functype_t
-var wasmtime_externref_data func(ptr uintptr) uintptr // returns *interface{} (externref data)
+var wasm_functype_delete func(ptr uintptr) // *wasm_functype_t
+var wasmtime_externref_new func(context uintptr, data uintptr, finalizer uintptr, out *wasmtime_externref_t) bool
+var wasmtime_externref_data func(context uintptr, ref *wasmtime_externref_t) uintptr // returns the `data` of wasmtime_externref_new
 var wasmtime_func_new func(store uintptr, ty uintptr, callback uintptr, env int, wrap int, ret *wasmtime_func_t)
 var wasmtime_caller_context func(caller uintptr) uintptr
 var wasmtime_trap_new func(message string, size int) *wasm_trap_t
@@ -42,40 +84,168 @@ var wasm_externtype_delete func(ptr uintptr)                    // ExternType
 var wasm_functype_as_externtype_const func(ptr uintptr) uintptr //*wasm_externtype_t // ExternType
 var wasmtime_context_get_data func(ptr uintptr) uintptr         // returns *interface{} (context data)
 var wasm_externtype_as_functype func(ptr uintptr) uintptr       // ExternType
+var wasmtime_func_call func(context uintptr, f *wasmtime_func_t, args *wasmtime_val_t, nargs int, results *wasmtime_val_t, nresults int, trap *uintptr) uintptr
+var wasmtime_func_type func(context uintptr, f *wasmtime_func_t) uintptr // returns *wasm_functype_t
+var wasmtime_error_message func(ptr uintptr, message *wasm_byte_vec_t)
+var wasm_trap_message func(ptr uintptr, message *wasm_byte_vec_t)
+var wasmtime_trap_code func(ptr uintptr, code *uint8) bool
+var wasm_trap_trace func(ptr uintptr, frames *wasm_frame_vec_t)
+var wasm_frame_vec_delete func(frames *wasm_frame_vec_t)
+var wasm_frame_func_index func(frame uintptr) uint32
+var wasmtime_frame_func_name func(frame uintptr) *wasm_byte_vec_t
+var wasmtime_frame_module_name func(frame uintptr) *wasm_byte_vec_t
+var wasm_frame_module_offset func(frame uintptr) uintptr
+var wasm_frame_func_offset func(frame uintptr) uintptr
+var wasmtime_caller_export_get func(caller uintptr, name string, size int, item *wasmtime_extern_t) bool
+var wasmtime_memory_data func(context uintptr, mem *wasmtime_memory_t) uintptr // returns *uint8
+var wasmtime_memory_data_size func(context uintptr, mem *wasmtime_memory_t) uintptr
+var wasmtime_memory_size func(context uintptr, mem *wasmtime_memory_t) uint64
+var wasmtime_memory_grow func(context uintptr, mem *wasmtime_memory_t, delta uint64, prev *uint64) uintptr
+var wasmtime_instance_new func(store uintptr, module uintptr, imports *wasmtime_extern_t, nimports int, instance *wasmtime_instance_t, trap *uintptr) uintptr
+var wasmtime_instance_export_get func(store uintptr, instance *wasmtime_instance_t, name string, size int, item *wasmtime_extern_t) bool
+var wasmtime_linker_new func(engine uintptr) uintptr
+var wasmtime_linker_delete func(linker uintptr)
+var wasmtime_linker_allow_shadowing func(linker uintptr, allow bool)
+var wasmtime_linker_define func(linker uintptr, store uintptr, module string, moduleLen int, name string, nameLen int, item *wasmtime_extern_t) uintptr
+var wasmtime_linker_define_func func(linker uintptr, module string, moduleLen int, name string, nameLen int, ty uintptr, callback uintptr, env int, finalizer uintptr) uintptr
+var wasmtime_linker_get func(linker uintptr, store uintptr, module string, moduleLen int, name string, nameLen int, item *wasmtime_extern_t) bool
+var wasmtime_linker_instantiate func(linker uintptr, store uintptr, module uintptr, instance *wasmtime_instance_t, trap *uintptr) uintptr
+var wasmtime_linker_instantiate_pre func(linker uintptr, module uintptr, ret *uintptr) uintptr
+var wasmtime_instance_pre_delete func(pre uintptr)
+var wasmtime_instance_pre_instantiate func(pre uintptr, store uintptr, instance *wasmtime_instance_t, trap *uintptr) uintptr
+var wasmtime_instance_pre_module func(pre uintptr) uintptr
 
-var libshimsptr uintptr
-var go_wasmtime_val_i32_set func(ptr *wasmtime_val_t, val int32)
-var go_wasmtime_val_i64_set func(ptr *wasmtime_val_t, val int64)
-var go_wasmtime_val_f32_set func(ptr *wasmtime_val_t, val float32)
-var go_wasmtime_val_f64_set func(ptr *wasmtime_val_t, val float64)
-var go_wasmtime_val_funcref_set func(ptr *wasmtime_val_t, val uintptr)   //val *Func)
-var go_wasmtime_val_externref_set func(ptr *wasmtime_val_t, val uintptr) //val interface{})
-var go_wasmtime_val_i32_get func(ptr *wasmtime_val_t) int32
-var go_wasmtime_val_i64_get func(ptr *wasmtime_val_t) int64
-var go_wasmtime_val_f32_get func(ptr *wasmtime_val_t) float32
-var go_wasmtime_val_f64_get func(ptr *wasmtime_val_t) float64
-var go_wasmtime_val_funcref_get func(ptr *wasmtime_val_t) *wasmtime_func_t // *Func
-var go_wasmtime_val_externref_get func(ptr *wasmtime_val_t) uintptr        // interface{}
-
-func init() {
-	libpath, err := findWasmtime()
-	if err != nil {
+// C function pointers to the Go callbacks of the same name.
+var goTrampolineNewPtr uintptr
+var goTrampolineWrapPtr uintptr
+var goFinalizeStorePtr uintptr
+var goFinalizeFuncNewPtr uintptr
+var goFinalizeFuncWrapPtr uintptr
+var goFinalizeExternrefPtr uintptr
+
+// LoadOptions configures how `Load` locates the native wasmtime library this
+// package is built on.
+type LoadOptions struct {
+	// LibraryPath is the full path to the wasmtime dynamic library. If empty
+	// the `WASMTIME_LIB` environment variable is used, and if that's empty too
+	// the library comes from the provider set with `RegisterLibraryProvider`
+	// or, without one, is searched for in `SearchDirs` and the standard system
+	// paths.
+	LibraryPath string
+	// SearchDirs are additional directories searched, before the directories
+	// in the `WASMTIME_LIB_DIR` environment variable and the standard system
+	// paths, if the library's path isn't given explicitly.
+	SearchDirs []string
+}
+
+var loadOnce sync.Once
+var loadErr error
+
+// libraryProvider, if set, supplies the path of the wasmtime library when
+// none is given explicitly.
+var libraryProvider func() (string, error)
+
+// RegisterLibraryProvider sets `provider` as the source of the wasmtime
+// library's path when neither `LoadOptions.LibraryPath` nor the
+// `WASMTIME_LIB` environment variable are set, taking the place of searching
+// the standard system paths.
+//
+// This is intended for packages such as `wasmtime/embedded` which ship the
+// library themselves, and must be called before the library is loaded,
+// typically from an `init` function.
+func RegisterLibraryProvider(provider func() (string, error)) {
+	libraryProvider = provider
+}
+
+// Load loads the native wasmtime library using `opts`.
+//
+// Loading happens at most once per process. Calling `Load` is optional: every
+// constructor in this package loads the library with the default options on
+// first use, panicking if that fails, so `Load` only needs to be called to
+// customize where the library is found or to handle a missing library
+// gracefully. Once the library has been loaded, or failed to load, later
+// calls return the result of the first one and `opts` is ignored.
+//
+// The returned error lists every path that was searched if the library
+// couldn't be found.
+func Load(opts LoadOptions) error {
+	loadOnce.Do(func() {
+		loadErr = loadLibraries(opts)
+	})
+	return loadErr
+}
+
+// mustLoad loads the native library with the default options if that hasn't
+// happened yet, panicking if it can't be loaded.
+func mustLoad() {
+	if err := Load(LoadOptions{}); err != nil {
 		panic(err)
 	}
+}
+
+func loadLibraries(opts LoadOptions) (err error) {
+	libpath := opts.LibraryPath
+	if libpath == "" {
+		libpath = os.Getenv("WASMTIME_LIB")
+	}
+	if libpath == "" && libraryProvider != nil {
+		if libpath, err = libraryProvider(); err != nil {
+			return err
+		}
+	}
+	if libpath == "" {
+		if libpath, err = findWasmtime(opts.SearchDirs...); err != nil {
+			return err
+		}
+	}
 	if libptr, err = load(libpath); err != nil {
-		panic(err)
+		return fmt.Errorf("failed to load '%s': %w", libpath, err)
+	}
+	if libVersion, libVersionKnown, err = detectVersion(libpath, libptr); err != nil {
+		return err
 	}
 
-	// Load the library functions
+	// Load the library functions. `purego.RegisterLibFunc` panics if a
+	// symbol is missing, which is reported as an error instead.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("'%s' is missing a required symbol: %v", libpath, r)
+		}
+	}()
 	purego.RegisterLibFunc(&wasm_engine_new, libptr, "wasm_engine_new")
 	purego.RegisterLibFunc(&wasm_engine_delete, libptr, "wasm_engine_delete")
+	purego.RegisterLibFunc(&wasm_engine_new_with_config, libptr, "wasm_engine_new_with_config")
+	purego.RegisterLibFunc(&wasm_config_new, libptr, "wasm_config_new")
+	purego.RegisterLibFunc(&wasm_config_delete, libptr, "wasm_config_delete")
+	purego.RegisterLibFunc(&wasmtime_config_debug_info_set, libptr, "wasmtime_config_debug_info_set")
+	purego.RegisterLibFunc(&wasmtime_config_wasm_reference_types_set, libptr, "wasmtime_config_wasm_reference_types_set")
+	purego.RegisterLibFunc(&wasmtime_config_wasm_simd_set, libptr, "wasmtime_config_wasm_simd_set")
+	purego.RegisterLibFunc(&wasmtime_config_wasm_relaxed_simd_set, libptr, "wasmtime_config_wasm_relaxed_simd_set")
+	purego.RegisterLibFunc(&wasmtime_config_wasm_bulk_memory_set, libptr, "wasmtime_config_wasm_bulk_memory_set")
+	purego.RegisterLibFunc(&wasmtime_config_wasm_multi_value_set, libptr, "wasmtime_config_wasm_multi_value_set")
+	purego.RegisterLibFunc(&wasmtime_config_wasm_multi_memory_set, libptr, "wasmtime_config_wasm_multi_memory_set")
+	purego.RegisterLibFunc(&wasmtime_config_wasm_memory64_set, libptr, "wasmtime_config_wasm_memory64_set")
+	purego.RegisterLibFunc(&wasmtime_config_wasm_tail_call_set, libptr, "wasmtime_config_wasm_tail_call_set")
+	purego.RegisterLibFunc(&wasmtime_config_consume_fuel_set, libptr, "wasmtime_config_consume_fuel_set")
+	purego.RegisterLibFunc(&wasmtime_config_epoch_interruption_set, libptr, "wasmtime_config_epoch_interruption_set")
+	purego.RegisterLibFunc(&wasmtime_config_max_wasm_stack_set, libptr, "wasmtime_config_max_wasm_stack_set")
+	purego.RegisterLibFunc(&wasmtime_config_strategy_set, libptr, "wasmtime_config_strategy_set")
+	purego.RegisterLibFunc(&wasmtime_config_cranelift_nan_canonicalization_set, libptr, "wasmtime_config_cranelift_nan_canonicalization_set")
+	purego.RegisterLibFunc(&wasmtime_config_cranelift_opt_level_set, libptr, "wasmtime_config_cranelift_opt_level_set")
+	purego.RegisterLibFunc(&wasmtime_config_target_set, libptr, "wasmtime_config_target_set")
 	purego.RegisterLibFunc(&wasmtime_store_new, libptr, "wasmtime_store_new")
 	purego.RegisterLibFunc(&wasmtime_store_delete, libptr, "wasmtime_store_delete")
+	purego.RegisterLibFunc(&wasmtime_store_limiter, libptr, "wasmtime_store_limiter")
+	purego.RegisterLibFunc(&wasmtime_context_set_fuel, libptr, "wasmtime_context_set_fuel")
+	purego.RegisterLibFunc(&wasmtime_context_get_fuel, libptr, "wasmtime_context_get_fuel")
+	purego.RegisterLibFunc(&wasmtime_context_set_epoch_deadline, libptr, "wasmtime_context_set_epoch_deadline")
+	purego.RegisterLibFunc(&wasmtime_engine_increment_epoch, libptr, "wasmtime_engine_increment_epoch")
 	purego.RegisterLibFunc(&wasmtime_store_context, libptr, "wasmtime_store_context")
 	purego.RegisterLibFunc(&wasmtime_module_new, libptr, "wasmtime_module_new")
 	purego.RegisterLibFunc(&wasmtime_module_delete, libptr, "wasmtime_module_delete")
+	purego.RegisterLibFunc(&wasmtime_module_serialize, libptr, "wasmtime_module_serialize")
+	purego.RegisterLibFunc(&wasmtime_module_deserialize, libptr, "wasmtime_module_deserialize")
 	purego.RegisterLibFunc(&wasmtime_error_delete, libptr, "wasmtime_error_delete")
-	purego.RegisterLibFunc(&wasmtime_wat2wasm, libptr, "wasmtime_wat2wasm")
 	purego.RegisterLibFunc(&wasm_byte_vec_delete, libptr, "wasm_byte_vec_delete")
 	purego.RegisterLibFunc(&wasm_valtype_new, libptr, "wasm_valtype_new")
 	purego.RegisterLibFunc(&wasm_valtype_kind, libptr, "wasm_valtype_kind")
@@ -83,6 +253,7 @@ func init() {
 	purego.RegisterLibFunc(&wasm_functype_new, libptr, "wasm_functype_new")
 	purego.RegisterLibFunc(&wasm_valtype_vec_new_uninitialized, libptr, "wasm_valtype_vec_new_uninitialized")
 	purego.RegisterLibFunc(&wasm_functype_delete, libptr, "wasm_functype_delete")
+	purego.RegisterLibFunc(&wasmtime_externref_new, libptr, "wasmtime_externref_new")
 	purego.RegisterLibFunc(&wasmtime_externref_data, libptr, "wasmtime_externref_data")
 	purego.RegisterLibFunc(&wasmtime_func_new, libptr, "wasmtime_func_new")
 	purego.RegisterLibFunc(&wasmtime_caller_context, libptr, "wasmtime_caller_context")
@@ -95,59 +266,111 @@ func init() {
 	purego.RegisterLibFunc(&wasm_functype_as_externtype_const, libptr, "wasm_functype_as_externtype_const")
 	purego.RegisterLibFunc(&wasmtime_context_get_data, libptr, "wasmtime_context_get_data")
 	purego.RegisterLibFunc(&wasm_externtype_as_functype, libptr, "wasm_externtype_as_functype")
+	purego.RegisterLibFunc(&wasmtime_func_call, libptr, "wasmtime_func_call")
+	purego.RegisterLibFunc(&wasmtime_func_type, libptr, "wasmtime_func_type")
+	purego.RegisterLibFunc(&wasmtime_error_message, libptr, "wasmtime_error_message")
+	purego.RegisterLibFunc(&wasm_trap_message, libptr, "wasm_trap_message")
+	purego.RegisterLibFunc(&wasmtime_trap_code, libptr, "wasmtime_trap_code")
+	purego.RegisterLibFunc(&wasm_trap_trace, libptr, "wasm_trap_trace")
+	purego.RegisterLibFunc(&wasm_frame_vec_delete, libptr, "wasm_frame_vec_delete")
+	purego.RegisterLibFunc(&wasm_frame_func_index, libptr, "wasm_frame_func_index")
+	purego.RegisterLibFunc(&wasmtime_frame_func_name, libptr, "wasmtime_frame_func_name")
+	purego.RegisterLibFunc(&wasmtime_frame_module_name, libptr, "wasmtime_frame_module_name")
+	purego.RegisterLibFunc(&wasm_frame_module_offset, libptr, "wasm_frame_module_offset")
+	purego.RegisterLibFunc(&wasm_frame_func_offset, libptr, "wasm_frame_func_offset")
+	purego.RegisterLibFunc(&wasmtime_caller_export_get, libptr, "wasmtime_caller_export_get")
+	purego.RegisterLibFunc(&wasmtime_memory_data, libptr, "wasmtime_memory_data")
+	purego.RegisterLibFunc(&wasmtime_memory_data_size, libptr, "wasmtime_memory_data_size")
+	purego.RegisterLibFunc(&wasmtime_memory_size, libptr, "wasmtime_memory_size")
+	purego.RegisterLibFunc(&wasmtime_memory_grow, libptr, "wasmtime_memory_grow")
+	purego.RegisterLibFunc(&wasmtime_instance_new, libptr, "wasmtime_instance_new")
+	purego.RegisterLibFunc(&wasmtime_instance_export_get, libptr, "wasmtime_instance_export_get")
+	purego.RegisterLibFunc(&wasmtime_linker_new, libptr, "wasmtime_linker_new")
+	purego.RegisterLibFunc(&wasmtime_linker_delete, libptr, "wasmtime_linker_delete")
+	purego.RegisterLibFunc(&wasmtime_linker_allow_shadowing, libptr, "wasmtime_linker_allow_shadowing")
+	purego.RegisterLibFunc(&wasmtime_linker_define, libptr, "wasmtime_linker_define")
+	purego.RegisterLibFunc(&wasmtime_linker_define_func, libptr, "wasmtime_linker_define_func")
+	purego.RegisterLibFunc(&wasmtime_linker_get, libptr, "wasmtime_linker_get")
+	purego.RegisterLibFunc(&wasmtime_linker_instantiate, libptr, "wasmtime_linker_instantiate")
+	purego.RegisterLibFunc(&wasmtime_linker_instantiate_pre, libptr, "wasmtime_linker_instantiate_pre")
+	purego.RegisterLibFunc(&wasmtime_instance_pre_delete, libptr, "wasmtime_instance_pre_delete")
+	purego.RegisterLibFunc(&wasmtime_instance_pre_instantiate, libptr, "wasmtime_instance_pre_instantiate")
+	purego.RegisterLibFunc(&wasmtime_instance_pre_module, libptr, "wasmtime_instance_pre_module")
 
-	libshims, err := findWasmtimeShims()
-	if err != nil {
-		panic(err)
-	}
-	if libshimsptr, err = load(libshims); err != nil {
-		panic(err)
-	}
+	// Go functions called by the library. These are created once since purego
+	// can only create a limited number of callbacks per process.
+	goTrampolineNewPtr = purego.NewCallback(goTrampolineNew)
+	goTrampolineWrapPtr = purego.NewCallback(goTrampolineWrap)
+	goFinalizeStorePtr = purego.NewCallback(goFinalizeStore)
+	goFinalizeFuncNewPtr = purego.NewCallback(goFinalizeFuncNew)
+	goFinalizeFuncWrapPtr = purego.NewCallback(goFinalizeFuncWrap)
+	goFinalizeExternrefPtr = purego.NewCallback(goFinalizeExternref)
 
-	purego.RegisterLibFunc(&go_wasmtime_val_i32_set, libshimsptr, "go_wasmtime_val_i32_set")
-	purego.RegisterLibFunc(&go_wasmtime_val_i64_set, libshimsptr, "go_wasmtime_val_i64_set")
-	purego.RegisterLibFunc(&go_wasmtime_val_f32_set, libshimsptr, "go_wasmtime_val_f32_set")
-	purego.RegisterLibFunc(&go_wasmtime_val_f64_set, libshimsptr, "go_wasmtime_val_f64_set")
-	purego.RegisterLibFunc(&go_wasmtime_val_funcref_set, libshimsptr, "go_wasmtime_val_funcref_set")
-	purego.RegisterLibFunc(&go_wasmtime_val_externref_set, libshimsptr, "go_wasmtime_val_externref_set")
-	purego.RegisterLibFunc(&go_wasmtime_val_i32_get, libshimsptr, "go_wasmtime_val_i32_get")
-	purego.RegisterLibFunc(&go_wasmtime_val_i64_get, libshimsptr, "go_wasmtime_val_i64_get")
-	purego.RegisterLibFunc(&go_wasmtime_val_f32_get, libshimsptr, "go_wasmtime_val_f32_get")
-	purego.RegisterLibFunc(&go_wasmtime_val_f64_get, libshimsptr, "go_wasmtime_val_f64_get")
-	purego.RegisterLibFunc(&go_wasmtime_val_funcref_get, libshimsptr, "go_wasmtime_val_funcref_get")
-	purego.RegisterLibFunc(&go_wasmtime_val_externref_get, libshimsptr, "go_wasmtime_val_externref_get")
+	// Optional library functions, which are missing when wasmtime was built
+	// without the corresponding feature.
+	registerOptional(&wasmtime_wat2wasm, libptr, "wasmtime_wat2wasm", FeatureWat)
+	registerOptional(&wasmtime_config_cache_config_load, libptr, "wasmtime_config_cache_config_load", FeatureCache)
+	registerOptional(&wasmtime_pooling_allocation_config_new, libptr, "wasmtime_pooling_allocation_config_new", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_delete, libptr, "wasmtime_pooling_allocation_config_delete", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_total_core_instances_set, libptr, "wasmtime_pooling_allocation_config_total_core_instances_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_total_memories_set, libptr, "wasmtime_pooling_allocation_config_total_memories_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_total_tables_set, libptr, "wasmtime_pooling_allocation_config_total_tables_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_max_memory_size_set, libptr, "wasmtime_pooling_allocation_config_max_memory_size_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_max_core_instance_size_set, libptr, "wasmtime_pooling_allocation_config_max_core_instance_size_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_max_memories_per_module_set, libptr, "wasmtime_pooling_allocation_config_max_memories_per_module_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_max_tables_per_module_set, libptr, "wasmtime_pooling_allocation_config_max_tables_per_module_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_table_elements_set, libptr, "wasmtime_pooling_allocation_config_table_elements_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_config_max_unused_warm_slots_set, libptr, "wasmtime_pooling_allocation_config_max_unused_warm_slots_set", FeaturePoolingAllocator)
+	registerOptional(&wasmtime_pooling_allocation_strategy_set, libptr, "wasmtime_pooling_allocation_strategy_set", FeaturePoolingAllocator)
+	return nil
 }
 
-// findWasmtime searches for the dynamic library in standard system paths.
-func findWasmtime() (string, error) {
-	switch runtime.GOOS {
-	case "windows":
-		// TODO: also handle libwasmtime.dll.a?
-		return findLibrary("wasmtime.dll", runtime.GOOS)
-	case "darwin":
-		return findLibrary("libwasmtime.dylib", runtime.GOOS)
-	default:
-		return findLibrary("libwasmtime.so", runtime.GOOS)
+// registerOptional is like `purego.RegisterLibFunc` except that a missing
+// symbol marks `feature` as unavailable rather than panicking. A feature is
+// available only if all of its symbols are present.
+func registerOptional(fptr interface{}, lib uintptr, name string, feature Feature) {
+	addr, err := symbol(lib, name)
+	if err != nil || addr == 0 {
+		features[feature] = false
+		return
+	}
+	purego.RegisterFunc(fptr, addr)
+	if _, ok := features[feature]; !ok {
+		features[feature] = true
 	}
 }
 
-// findWasmtimeShims searches for the dynamic library in standard system paths.
-func findWasmtimeShims() (string, error) {
+// findWasmtime searches for the dynamic library in `dirs` and then standard system paths.
+func findWasmtime(dirs ...string) (string, error) {
 	switch runtime.GOOS {
 	case "windows":
 		// TODO: also handle libwasmtime.dll.a?
-		return findLibrary("wasmtime-shims.dll", runtime.GOOS)
+		return findLibrary("wasmtime.dll", runtime.GOOS, dirs...)
 	case "darwin":
-		return findLibrary("libwasmtime-shims.dylib", runtime.GOOS)
+		return findLibrary("libwasmtime.dylib", runtime.GOOS, dirs...)
 	default:
-		return findLibrary("libwasmtime-shims.so", runtime.GOOS)
+		return findLibrary("libwasmtime.so", runtime.GOOS, dirs...)
 	}
 }
 
 // findLibrary searches for a dynamic library by name across standard system paths.
 // It returns the full path to the library if found, or an error listing all searched paths.
+//
+// Directories named by `WASMTIME_LIB_DIR` are searched after `dirs`, followed
+// by the directory of the running executable, so that a library shipped next
+// to the binary takes precedence over one installed on the system. The
+// standard system paths and the current working directory are searched last.
+// Within each directory a versioned library, such as `libwasmtime.so.33`, is
+// used when the unversioned name doesn't exist.
 func findLibrary(libName, goos string, dirs ...string) (string, error) {
 	libExt, commonPaths := findLibDirs(goos)
+	dirs = append(dirs, filepath.SplitList(os.Getenv("WASMTIME_LIB_DIR"))...)
+
+	// Include the directory of the running executable
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+
 	dirs = append(dirs, commonPaths...)
 
 	// Append the correct extension if missing
@@ -168,6 +391,15 @@ func findLibrary(libName, goos string, dirs ...string) (string, error) {
 		if fi, err := os.Stat(filename); err == nil && !fi.IsDir() {
 			return filename, nil // Library found
 		}
+
+		pattern := versionedLibPattern(libName, libExt, goos)
+		if pattern == "" {
+			continue
+		}
+		searched = append(searched, filepath.Join(dir, pattern))
+		if filename := findVersionedLibrary(dir, pattern); filename != "" {
+			return filename, nil // Versioned library found
+		}
 	}
 
 	// Construct error message listing all searched paths
@@ -180,6 +412,58 @@ func findLibrary(libName, goos string, dirs ...string) (string, error) {
 	return "", errors.New(sb.String())
 }
 
+// versionedLibPattern returns the glob pattern matching versioned names of the
+// library `libName`, e.g. `libwasmtime.so.*`, or "" if the OS doesn't version
+// library names.
+func versionedLibPattern(libName, libExt, goos string) string {
+	switch goos {
+	case "windows":
+		return ""
+	case "darwin":
+		return strings.TrimSuffix(libName, libExt) + ".*" + libExt
+	default:
+		return libName + ".*"
+	}
+}
+
+// findVersionedLibrary returns the file in `dir` matching `pattern` with the
+// highest version, or "" if there are none.
+func findVersionedLibrary(dir, pattern string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+	best, bestVersion := "", []int(nil)
+	for _, match := range matches {
+		if fi, err := os.Stat(match); err != nil || fi.IsDir() {
+			continue
+		}
+		version := fileVersion(filepath.Base(match))
+		if best == "" || compareVersions(version, bestVersion) > 0 {
+			best, bestVersion = match, version
+		}
+	}
+	return best
+}
+
+// fileVersion extracts the numeric components of the version in a library file
+// name, e.g. `[33 0 1]` for `libwasmtime.so.33.0.1`.
+func fileVersion(name string) []int {
+	var ret []int
+	for _, part := range strings.Split(name, ".") {
+		if n, err := strconv.Atoi(part); err == nil {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
+
 // findLibDirs returns the library extension, relevant environment path, and common library directories based on the OS.
 func findLibDirs(goos string) (string, []string) {
 	switch goos {
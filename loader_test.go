@@ -0,0 +1,76 @@
+package wasmtime
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindLibrary(t *testing.T) {
+	dir := t.TempDir()
+	missing := t.TempDir()
+	libExt, _ := findLibDirs(runtime.GOOS)
+	lib := filepath.Join(dir, "libexample"+libExt)
+	require.NoError(t, os.WriteFile(lib, nil, 0o644))
+
+	path, err := findLibrary("libexample", runtime.GOOS, missing, dir)
+	require.NoError(t, err)
+	require.Equal(t, lib, path)
+
+	_, err = findLibrary("libmissing", runtime.GOOS, missing)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), filepath.Join(missing, "libmissing"+libExt))
+}
+
+func TestFindLibraryVersioned(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("library names aren't versioned on windows")
+	}
+	dir := t.TempDir()
+	libExt, _ := findLibDirs(runtime.GOOS)
+	pattern := versionedLibPattern("libexample"+libExt, libExt, runtime.GOOS)
+	for _, version := range []string{"9", "33", "33.0.1", "4"} {
+		name := strings.Replace(pattern, "*", version, 1)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	path, err := findLibrary("libexample", runtime.GOOS, dir)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, strings.Replace(pattern, "*", "33.0.1", 1)), path)
+}
+
+func TestFindLibraryEnvDir(t *testing.T) {
+	dir := t.TempDir()
+	libExt, _ := findLibDirs(runtime.GOOS)
+	lib := filepath.Join(dir, "libexample"+libExt)
+	require.NoError(t, os.WriteFile(lib, nil, 0o644))
+
+	t.Setenv("WASMTIME_LIB_DIR", dir)
+	path, err := findLibrary("libexample", runtime.GOOS)
+	require.NoError(t, err)
+	require.Equal(t, lib, path)
+}
+
+func TestFindLibraryExecutableDir(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	libExt, commonPaths := findLibDirs(runtime.GOOS)
+
+	_, err = findLibrary("libmissing", runtime.GOOS)
+	require.Error(t, err)
+	exePath := filepath.Join(filepath.Dir(exe), "libmissing"+libExt)
+	require.Contains(t, err.Error(), exePath)
+
+	// It's searched before the system paths.
+	for _, dir := range commonPaths {
+		path := filepath.Join(dir, "libmissing"+libExt)
+		if dir == "" || path == exePath {
+			continue
+		}
+		require.Less(t, strings.Index(err.Error(), exePath), strings.Index(err.Error(), path), dir)
+	}
+}
@@ -7,3 +7,7 @@ import "github.com/ebitengine/purego"
 func load(name string) (uintptr, error) {
 	return purego.Dlopen(name, purego.RTLD_NOW|purego.RTLD_GLOBAL)
 }
+
+func symbol(lib uintptr, name string) (uintptr, error) {
+	return purego.Dlsym(lib, name)
+}
@@ -0,0 +1,69 @@
+package wasmtime
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+type wasmtime_memory_t struct {
+	store_id uint64  // C.wasmtime_store_id_t
+	private  uintptr // C.size_t
+}
+
+// Memory instance is the runtime representation of a linear memory.
+// It holds a vector of bytes and an optional maximum size, if one was specified at the definition site of the memory.
+// Read more in [spec](https://webassembly.github.io/spec/core/exec/runtime.html#memory-instances)
+// In wasmtime-go, you can get the vector of bytes by the unsafe pointer of memory from `Memory.Data()`, or go style byte slice from `Memory.UnsafeData()`
+type Memory struct {
+	val wasmtime_memory_t
+}
+
+func mkMemory(val *wasmtime_memory_t) *Memory {
+	return &Memory{val: *val}
+}
+
+// Data returns the raw pointer in memory of where this memory starts
+func (mem *Memory) Data(store Storelike) unsafe.Pointer {
+	ret := unsafe.Pointer(wasmtime_memory_data(uintptr(store.Context()), &mem.val))
+	runtime.KeepAlive(store)
+	return ret
+}
+
+// UnsafeData returns the raw memory backed by this `Memory` as a byte slice (`[]byte`).
+//
+// This is not a safe method to call, hence the "unsafe" in the name. The byte
+// slice returned from this function is not managed by the Go garbage collector.
+// You need to ensure that `m`, the original `Memory`, lives longer than the
+// `[]byte` returned.
+//
+// Note that you may need to use `runtime.KeepAlive` to keep the original memory
+// `m` alive for long enough while you're using the `[]byte` slice. If the
+// `[]byte` slice is used after `m` is GC'd then that is undefined behavior.
+func (mem *Memory) UnsafeData(store Storelike) []byte {
+	return unsafe.Slice((*byte)(mem.Data(store)), mem.DataSize(store))
+}
+
+// DataSize returns the size, in bytes, that `Data()` is valid for
+func (mem *Memory) DataSize(store Storelike) uintptr {
+	ret := wasmtime_memory_data_size(uintptr(store.Context()), &mem.val)
+	runtime.KeepAlive(store)
+	return ret
+}
+
+// Size returns the size, in wasm pages, of this memory
+func (mem *Memory) Size(store Storelike) uint64 {
+	ret := wasmtime_memory_size(uintptr(store.Context()), &mem.val)
+	runtime.KeepAlive(store)
+	return ret
+}
+
+// Grow grows this memory by `delta` pages
+func (mem *Memory) Grow(store Storelike, delta uint64) (uint64, error) {
+	var prev uint64
+	err := wasmtime_memory_grow(uintptr(store.Context()), &mem.val, delta, &prev)
+	runtime.KeepAlive(store)
+	if err != 0 {
+		return 0, mkError(unsafe.Pointer(err))
+	}
+	return prev, nil
+}
@@ -1,8 +1,12 @@
 package wasmtime
 
 import (
+	"maps"
 	"runtime"
+	"sync"
 	"unsafe"
+
+	"github.com/hybridgroup/wasmtime/wasmparse"
 )
 
 // Module is a module which collects definitions for types, functions, tables, memories, and globals.
@@ -10,6 +14,12 @@ import (
 // Modules organized WebAssembly programs as the unit of deployment, loading, and compilation.
 type Module struct {
 	_ptr unsafe.Pointer //*C.wasmtime_module_t
+	// The custom sections of the binary the module was compiled from, which
+	// the C API doesn't expose.
+	customs []wasmparse.CustomSection
+	// The decoded `name` custom section, parsed on first use.
+	namesOnce   sync.Once
+	parsedNames *wasmparse.Names
 }
 
 // NewModule compiles a new `Module` from the `wasm` provided with the given configuration
@@ -24,6 +34,49 @@ func NewModule(engine *Engine, wasm []byte) (*Module, error) {
 		return nil, mkError(unsafe.Pointer(err))
 	}
 
+	module := mkModule(unsafe.Pointer(ptr))
+	module.customs = copyCustomSections(wasm)
+	return module, nil
+}
+
+// copyCustomSections returns copies of the custom sections in `wasm`, so that
+// the caller remains free to reuse its buffer.
+func copyCustomSections(wasm []byte) []wasmparse.CustomSection {
+	// wasmtime has already accepted the module, so this can't fail.
+	customs, _ := wasmparse.CustomSections(wasm)
+	for i := range customs {
+		customs[i].Data = append([]byte(nil), customs[i].Data...)
+	}
+	return customs
+}
+
+// NewModuleDeserialize decodes and deserializes in-memory bytes previously
+// created by `Module.Serialize`.
+//
+// This function does not take a WebAssembly binary as input. It takes
+// as input the results of a previous call to `Serialize`, and only takes
+// that as input.
+//
+// If deserialization is successful then a compiled module is returned,
+// otherwise nil and an error are returned.
+//
+// Note that to deserialize successfully the bytes provided must have been
+// produced with an `Engine` that has the same compilation options as the
+// provided engine, and from the same version of this library.
+//
+// Deserializing arbitrary bytes is unsafe, since they contain machine code
+// which is run as is: only pass bytes from a trusted source. Modules created
+// this way have no custom sections.
+func NewModuleDeserialize(engine *Engine, encoded []byte) (*Module, error) {
+	var ptr uintptr //*C.wasmtime_module_t
+	err := wasmtime_module_deserialize(uintptr(engine.ptr()), encoded, len(encoded), &ptr)
+	runtime.KeepAlive(engine)
+	runtime.KeepAlive(encoded)
+
+	if err != 0 {
+		return nil, mkError(unsafe.Pointer(err))
+	}
+
 	return mkModule(unsafe.Pointer(ptr)), nil
 }
 
@@ -46,3 +99,104 @@ func (m *Module) Close() {
 	wasmtime_module_delete(uintptr(m._ptr))
 	m._ptr = nil
 }
+
+// Serialize will convert this in-memory compiled module into a list of bytes.
+//
+// The purpose of this method is to extract an artifact which can be stored
+// elsewhere from this `Module`. The returned bytes can, for example, be stored
+// on disk or in an object store. The `NewModuleDeserialize` function can be
+// used to deserialize the returned bytes at a later date to get the module
+// back.
+func (m *Module) Serialize() ([]byte, error) {
+	var retVec wasm_byte_vec_t
+	err := wasmtime_module_serialize(uintptr(m.ptr()), &retVec)
+	runtime.KeepAlive(m)
+
+	if err != 0 {
+		return nil, mkError(unsafe.Pointer(err))
+	}
+	ret := make([]byte, retVec.size)
+	copy(ret, unsafe.Slice(retVec.data, retVec.size))
+	wasm_byte_vec_delete(&retVec)
+	return ret, nil
+}
+
+func (m *Module) ptr() unsafe.Pointer {
+	ret := m._ptr
+	if ret == nil {
+		panic("object has been closed already")
+	}
+	//maybeGC()
+	return ret
+}
+
+// CustomSections returns the contents of the custom sections called `name` in
+// the binary the module was compiled from, in the order they appear.
+//
+// Custom sections are kept from the bytes given to `NewModule`, so a module
+// holds on to a copy of all of them, including debug information.
+func (m *Module) CustomSections(name string) [][]byte {
+	var ret [][]byte
+	for _, custom := range m.customs {
+		if custom.Name == name {
+			ret = append(ret, custom.Data)
+		}
+	}
+	return ret
+}
+
+// Producers returns the contents of the module's `producers` custom section,
+// which records the languages and tools it was built with. It returns nil if
+// the module has no such section, and an error if it's malformed.
+func (m *Module) Producers() (*wasmparse.Producers, error) {
+	sections := m.CustomSections("producers")
+	if len(sections) == 0 {
+		return nil, nil
+	}
+	return wasmparse.ParseProducers(sections[0])
+}
+
+// names returns the module's decoded `name` custom section, or nil if it has
+// none or it's malformed. The section is only decoded the first time.
+func (m *Module) names() *wasmparse.Names {
+	m.namesOnce.Do(func() {
+		sections := m.CustomSections("name")
+		if len(sections) == 0 {
+			return
+		}
+		if names, err := wasmparse.ParseNames(sections[0]); err == nil {
+			m.parsedNames = names
+		}
+	})
+	return m.parsedNames
+}
+
+// Name returns the name of the module recorded in its `name` custom section,
+// or an empty string if it has none.
+func (m *Module) Name() string {
+	if names := m.names(); names != nil && names.Module != nil {
+		return *names.Module
+	}
+	return ""
+}
+
+// FunctionNames returns the names of the module's functions recorded in its
+// `name` custom section, keyed by function index. The indices are the same as
+// those of `Frame.FuncIndex`, so traps can be rendered with symbols even when
+// `Frame.FuncName` returns nil.
+func (m *Module) FunctionNames() map[uint32]string {
+	if names := m.names(); names != nil && names.Funcs != nil {
+		return maps.Clone(names.Funcs)
+	}
+	return map[uint32]string{}
+}
+
+// LocalNames returns the names of the parameters and locals of the function
+// at `funcIndex` recorded in the module's `name` custom section, keyed by
+// local index.
+func (m *Module) LocalNames(funcIndex uint32) map[uint32]string {
+	if names := m.names(); names != nil && names.Locals[funcIndex] != nil {
+		return maps.Clone(names.Locals[funcIndex])
+	}
+	return map[uint32]string{}
+}
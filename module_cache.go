@@ -0,0 +1,181 @@
+package wasmtime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheExt is the extension of the files in a `ModuleCache`'s directory.
+const cacheExt = ".cwasm"
+
+// ModuleCache stores compiled modules on disk, so that compiling the same
+// wasm again, even in another process, only needs to load the machine code.
+//
+// Entries are keyed by the SHA-256 of the wasm bytes, the fingerprint of the
+// engine's `Config`, the version of the wasmtime library and the platform, so
+// a change to any of them compiles the module afresh. Each entry is stored
+// with a checksum: entries which are corrupt or which wasmtime refuses to load
+// are deleted and the module is recompiled.
+//
+// The cache directory must only be writable by trusted users, since the
+// machine code in it is run as is.
+type ModuleCache struct {
+	dir     string
+	maxSize int64
+	// Serializes evictions. Entries are written by renaming them into place,
+	// so loading and storing modules needs no lock: concurrent writers of the
+	// same entry store the same module, and readers never see a partial one.
+	evictMu sync.Mutex
+}
+
+// NewModuleCache returns a cache storing modules in `dir`, which is created if
+// needed. Once the entries total more than `maxSize` bytes the least recently
+// used ones are evicted; a `maxSize` of zero or less leaves the cache
+// unbounded.
+func NewModuleCache(dir string, maxSize int64) (*ModuleCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &ModuleCache{dir: dir, maxSize: maxSize}, nil
+}
+
+// NewModule returns the module compiled from `wasm` for `engine`, loading it
+// from the cache if possible and otherwise compiling it with `NewModule` and
+// storing it. Failing to store the module isn't an error, since the cache is
+// only an optimization.
+//
+// NewModule may be called from multiple goroutines at once. Modules which
+// aren't cached yet are then compiled by each of them.
+func (c *ModuleCache) NewModule(engine *Engine, wasm []byte) (*Module, error) {
+	version := "unknown"
+	if libVersionKnown {
+		version = libVersion.String()
+	}
+	path := filepath.Join(c.dir, cacheKey(wasm, engine.fingerprint, version)+cacheExt)
+
+	if encoded, ok := readCacheEntry(path); ok {
+		if module, err := NewModuleDeserialize(engine, encoded); err == nil {
+			module.customs = copyCustomSections(wasm)
+			// Record the use for eviction.
+			now := time.Now()
+			_ = os.Chtimes(path, now, now)
+			return module, nil
+		}
+		// wasmtime rejected the entry, so it can't be of any use.
+		os.Remove(path)
+	}
+
+	module, err := NewModule(engine, wasm)
+	if err != nil {
+		return nil, err
+	}
+	if encoded, err := module.Serialize(); err == nil {
+		if writeCacheEntry(path, encoded) == nil {
+			c.evict()
+		}
+	}
+	return module, nil
+}
+
+// cacheKey returns the name of the cache entry for `wasm` compiled by an
+// engine with the configuration `fingerprint` and library `version`.
+func cacheKey(wasm []byte, fingerprint, version string) string {
+	wasmSum := sha256.Sum256(wasm)
+	h := sha256.New()
+	for _, part := range []string{hex.EncodeToString(wasmSum[:]), fingerprint, version, runtime.GOOS, runtime.GOARCH} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readCacheEntry returns the serialized module stored at `path`, deleting the
+// file if its checksum doesn't match.
+func readCacheEntry(path string) ([]byte, bool) {
+	entry, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if len(entry) >= sha256.Size {
+		sum, encoded := entry[:sha256.Size], entry[sha256.Size:]
+		if got := sha256.Sum256(encoded); bytes.Equal(got[:], sum) {
+			return encoded, true
+		}
+	}
+	os.Remove(path)
+	return nil, false
+}
+
+// writeCacheEntry stores `encoded` at `path` preceded by its checksum.
+func writeCacheEntry(path string, encoded []byte) error {
+	// Write to a temporary file first and rename it into place so that
+	// concurrent processes never read a partially written entry.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	sum := sha256.Sum256(encoded)
+	if _, err := tmp.Write(sum[:]); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// evict deletes the least recently used entries until the cache fits in
+// `maxSize`.
+func (c *ModuleCache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	var entries []os.FileInfo
+	total := int64(0)
+	for _, dirEntry := range dirEntries {
+		if !strings.HasSuffix(dirEntry.Name(), cacheExt) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		entries = append(entries, info)
+		total += info.Size()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, info := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		if os.Remove(filepath.Join(c.dir, info.Name())) == nil {
+			total -= info.Size()
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package wasmtime
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hybridgroup/wasmtime/internal/wasmtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKey(t *testing.T) {
+	wasm := wasmtest.Module()
+	key := cacheKey(wasm, "fp", "33.0.0")
+	require.Len(t, key, 64)
+	require.Equal(t, key, cacheKey(wasm, "fp", "33.0.0"))
+	require.NotEqual(t, key, cacheKey(wasmtest.Module(wasmtest.Section(0, wasmtest.Name("x"))), "fp", "33.0.0"))
+	require.NotEqual(t, key, cacheKey(wasm, "other", "33.0.0"))
+	require.NotEqual(t, key, cacheKey(wasm, "fp", "34.0.0"))
+}
+
+func TestCacheEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entry"+cacheExt)
+	_, ok := readCacheEntry(path)
+	require.False(t, ok)
+
+	require.NoError(t, writeCacheEntry(path, []byte("compiled")))
+	encoded, ok := readCacheEntry(path)
+	require.True(t, ok)
+	require.Equal(t, []byte("compiled"), encoded)
+
+	// A corrupt entry is deleted.
+	entry, err := os.ReadFile(path)
+	require.NoError(t, err)
+	entry[len(entry)-1] ^= 1
+	require.NoError(t, os.WriteFile(path, entry, 0o644))
+	_, ok = readCacheEntry(path)
+	require.False(t, ok)
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, os.WriteFile(path, []byte("short"), 0o644))
+	_, ok = readCacheEntry(path)
+	require.False(t, ok)
+}
+
+// Concurrent writers of the same entry need no lock, since readers only ever
+// see one of the complete entries.
+func TestCacheEntryConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entry"+cacheExt)
+	encoded := make([]byte, 1<<16)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := writeCacheEntry(path, encoded); err != nil {
+					t.Error(err)
+				}
+				if got, ok := readCacheEntry(path); ok && len(got) != len(encoded) {
+					t.Errorf("read a partial entry of %d bytes", len(got))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	got, ok := readCacheEntry(path)
+	require.True(t, ok)
+	require.Equal(t, encoded, got)
+}
+
+func TestModuleCacheEvict(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewModuleCache(filepath.Join(dir, "cache"), 250)
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i, entry := range []string{"a", "b", "c"} {
+		path := filepath.Join(cache.dir, entry+cacheExt)
+		require.NoError(t, os.WriteFile(path, make([]byte, 100), 0o644))
+		mtime := now.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	// Other files don't count towards the size.
+	require.NoError(t, os.WriteFile(filepath.Join(cache.dir, "other"), make([]byte, 1000), 0o644))
+
+	cache.evict()
+	names := func() []string {
+		entries, err := os.ReadDir(cache.dir)
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	require.Equal(t, []string{"b.cwasm", "c.cwasm", "other"}, names())
+
+	cache.maxSize = 0
+	require.NoError(t, os.WriteFile(filepath.Join(cache.dir, "d"+cacheExt), make([]byte, 1000), 0o644))
+	cache.evict()
+	require.Len(t, names(), 4)
+}
+
+func TestModuleCache(t *testing.T) {
+	cache, err := NewModuleCache(t.TempDir(), 0)
+	require.NoError(t, err)
+	wasm := wasmtest.Module(wasmtest.Section(0, wasmtest.Name("manifest"), []byte("v1")))
+	engine := NewEngine()
+
+	m, err := cache.NewModule(engine, wasm)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("v1")}, m.CustomSections("manifest"))
+	entries, err := filepath.Glob(filepath.Join(cache.dir, "*"+cacheExt))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// A hit restores the custom sections too.
+	m, err = cache.NewModule(engine, wasm)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("v1")}, m.CustomSections("manifest"))
+
+	// A corrupt entry falls back to compiling the module.
+	require.NoError(t, os.WriteFile(entries[0], []byte("corrupt"), 0o644))
+	_, err = cache.NewModule(engine, wasm)
+	require.NoError(t, err)
+	_, ok := readCacheEntry(entries[0])
+	require.True(t, ok)
+
+	_, err = cache.NewModule(engine, []byte{1})
+	require.Error(t, err)
+}
+
+func TestModuleCacheConcurrent(t *testing.T) {
+	cache, err := NewModuleCache(t.TempDir(), 1)
+	require.NoError(t, err)
+	engine := NewEngine()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			wasm := wasmtest.Module(wasmtest.Section(0, wasmtest.Name("manifest"), []byte{byte(i % 2)}))
+			for j := 0; j < 5; j++ {
+				m, err := cache.NewModule(engine, wasm)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got := m.CustomSections("manifest"); len(got) != 1 || got[0][0] != byte(i%2) {
+					t.Errorf("got the wrong module: %v", got)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestModuleSerialize(t *testing.T) {
+	engine := NewEngine()
+	m, err := NewModule(engine, wasmtest.Module())
+	require.NoError(t, err)
+	encoded, err := m.Serialize()
+	require.NoError(t, err)
+	_, err = NewModuleDeserialize(engine, encoded)
+	require.NoError(t, err)
+	_, err = NewModuleDeserialize(engine, []byte("not a module"))
+	require.Error(t, err)
+}
@@ -3,6 +3,8 @@ package wasmtime
 import (
 	"testing"
 
+	"github.com/hybridgroup/wasmtime/internal/wasmtest"
+	"github.com/hybridgroup/wasmtime/wasmparse"
 	"github.com/stretchr/testify/require"
 )
 
@@ -12,3 +14,59 @@ func TestModule(t *testing.T) {
 	_, err = NewModule(NewEngine(), []byte{1})
 	require.Error(t, err)
 }
+
+func TestModuleCustomSections(t *testing.T) {
+	producers := wasmtest.Vec(wasmtest.Cat(wasmtest.Name("processed-by"), wasmtest.Vec(wasmtest.Cat(wasmtest.Name("tinygo"), wasmtest.Name("0.33.0")))))
+	wasm := wasmtest.Module(
+		wasmtest.Section(0, wasmtest.Name("manifest"), []byte("v1")),
+		wasmtest.Section(0, wasmtest.Name("producers"), producers),
+		wasmtest.Section(0, wasmtest.Name("manifest"), []byte("v2")),
+	)
+	m, err := NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	// The sections are copied out of the caller's buffer.
+	for i := range wasm {
+		wasm[i] = 0
+	}
+	require.Equal(t, [][]byte{[]byte("v1"), []byte("v2")}, m.CustomSections("manifest"))
+	require.Nil(t, m.CustomSections("missing"))
+
+	p, err := m.Producers()
+	require.NoError(t, err)
+	require.Equal(t, []wasmparse.Producer{{Name: "tinygo", Version: "0.33.0"}}, p.ProcessedBy)
+
+	m, err = NewModule(NewEngine(), wasmtest.Module())
+	require.NoError(t, err)
+	p, err = m.Producers()
+	require.NoError(t, err)
+	require.Nil(t, p)
+}
+
+func TestModuleNames(t *testing.T) {
+	wasm := wasmtest.Module(
+		wasmtest.Section(1, wasmtest.Vec([]byte{0x60, 1, 0x7f, 0})),
+		wasmtest.Section(3, wasmtest.Vec([]byte{0})),
+		wasmtest.Section(10, wasmtest.Vec(wasmtest.Body(0x0b))),
+		wasmtest.Section(0, wasmtest.Name("name"),
+			wasmtest.Section(0, wasmtest.Name("plugin")),
+			wasmtest.Section(1, wasmtest.Vec(wasmtest.Cat([]byte{0}, wasmtest.Name("run")))),
+			wasmtest.Section(2, wasmtest.Vec(wasmtest.Cat([]byte{0}, wasmtest.Vec(wasmtest.Cat([]byte{0}, wasmtest.Name("n")))))),
+		),
+	)
+	m, err := NewModule(NewEngine(), wasm)
+	require.NoError(t, err)
+	require.Equal(t, "plugin", m.Name())
+	require.Equal(t, map[uint32]string{0: "run"}, m.FunctionNames())
+	require.Equal(t, map[uint32]string{0: "n"}, m.LocalNames(0))
+	require.Empty(t, m.LocalNames(1))
+
+	// The names are decoded once, and callers get their own copies.
+	m.FunctionNames()[0] = "changed"
+	require.Equal(t, map[uint32]string{0: "run"}, m.FunctionNames())
+	require.Same(t, m.names(), m.names())
+
+	m, err = NewModule(NewEngine(), wasmtest.Module())
+	require.NoError(t, err)
+	require.Equal(t, "", m.Name())
+	require.Empty(t, m.FunctionNames())
+}
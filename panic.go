@@ -0,0 +1,68 @@
+package wasmtime
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// HostPanic is a panic raised by a host function, along with the stack of the
+// goroutine at the time it panicked.
+//
+// When a host function panics, wasm is unwound and the panic is raised again
+// from the `Func.Call` which entered wasm, with a `*HostPanic` as its value so
+// that the original stack isn't lost. Alternatively `Store.SetTrapOnPanic`
+// turns such panics into a `*Trap` returned from the call instead.
+type HostPanic struct {
+	// Value is the value the host function panicked with.
+	Value interface{}
+	// Stack is the stack of the panicking goroutine, formatted like
+	// `runtime/debug.Stack`.
+	Stack []byte
+}
+
+func (p *HostPanic) Error() string {
+	return fmt.Sprintf("go panicked: %v\n\n%s", p.Value, p.Stack)
+}
+
+// Unwrap returns the panic value if it's an error, or nil otherwise.
+func (p *HostPanic) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// recoverHostPanic is deferred by the trampolines into host functions to
+// capture a panic, along with the panicking goroutine's stack, into `p`.
+func recoverHostPanic(p **HostPanic) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	// A panic propagated out of a nested call into wasm already carries the
+	// stack it originated from.
+	if hostPanic, ok := r.(*HostPanic); ok {
+		*p = hostPanic
+		return
+	}
+	*p = &HostPanic{Value: r, Stack: debug.Stack()}
+}
+
+// panicTrap records `p` to be propagated once wasm returns to Go, and returns
+// a trap to unwind wasm until then.
+func (data *storeData) panicTrap(p *HostPanic) uintptr {
+	data.lastPanic = p
+	trap := NewTrap(fmt.Sprintf("go panicked: %v", p.Value))
+	runtime.SetFinalizer(trap, nil)
+	return uintptr(trap.ptr())
+}
+
+// returnTrap hands ownership of `trap` returned by a host function over to
+// wasm, recording the Go error it carries to be attached to the trap which
+// comes out of wasm.
+func (data *storeData) returnTrap(trap *Trap) uintptr {
+	data.lastTrapCause = trap.cause
+	runtime.SetFinalizer(trap, nil)
+	ret := trap.ptr()
+	trap._ptr = nil
+	return uintptr(ret)
+}
@@ -0,0 +1,65 @@
+package wasmtime
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func panicsWith(value interface{}) (p *HostPanic) {
+	defer recoverHostPanic(&p)
+	panic(value)
+}
+
+func TestRecoverHostPanic(t *testing.T) {
+	p := panicsWith("boom")
+	require.NotNil(t, p)
+	require.Equal(t, "boom", p.Value)
+	require.Contains(t, string(p.Stack), "panicsWith")
+	require.Contains(t, p.Error(), "boom")
+	require.Nil(t, p.Unwrap())
+
+	p = panicsWith(io.ErrUnexpectedEOF)
+	require.True(t, errors.Is(p, io.ErrUnexpectedEOF))
+
+	// Panics propagated through nested calls keep their original stack.
+	require.Same(t, p, panicsWith(p))
+
+	func() {
+		defer recoverHostPanic(&p)
+		p = nil
+	}()
+	require.Nil(t, p)
+}
+
+func TestHostPanicPropagates(t *testing.T) {
+	store := NewStore(NewEngine())
+	f := WrapFunc(store, func() {
+		panic("boom")
+	})
+	defer func() {
+		p, ok := recover().(*HostPanic)
+		require.True(t, ok)
+		require.Equal(t, "boom", p.Value)
+		require.Contains(t, string(p.Stack), "TestHostPanicPropagates")
+	}()
+	f.Call(store)
+	t.Fatal("expected a panic")
+}
+
+func TestHostPanicAsTrap(t *testing.T) {
+	store := NewStore(NewEngine())
+	store.SetTrapOnPanic(true)
+	i32 := NewValType(KindI32)
+	f := NewFunc(store, NewFuncType(nil, []*ValType{i32}), func(*Caller, []Val) ([]Val, *Trap) {
+		panic("boom")
+	})
+	_, err := f.Call(store)
+	var trap *Trap
+	require.True(t, errors.As(err, &trap))
+	require.NotNil(t, trap.Panic())
+	require.Equal(t, "boom", trap.Panic().Value)
+	require.NotEmpty(t, trap.Panic().Stack)
+}
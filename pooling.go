@@ -0,0 +1,85 @@
+package wasmtime
+
+import (
+	"runtime"
+)
+
+// PoolingAllocationConfig configures the pooling instance allocator, which
+// reserves the memory for a fixed number of instances up front and reuses it,
+// rather than mapping and unmapping memory for every instance. This speeds up
+// instantiation considerably when many short-lived instances are created.
+//
+// Zero fields keep wasmtime's defaults. Instantiation fails with an error
+// once a limit is reached, such as all of the instance slots being in use by
+// stores which haven't been closed.
+//
+// See https://docs.wasmtime.dev/api/wasmtime/struct.PoolingAllocationConfig.html
+// for the meaning of each setting.
+type PoolingAllocationConfig struct {
+	// The most core instances which may be alive at once.
+	TotalCoreInstances uint32
+	// The most linear memories which may be alive at once.
+	TotalMemories uint32
+	// The most tables which may be alive at once.
+	TotalTables uint32
+	// The most bytes a linear memory may grow to.
+	MaxMemorySize uint64
+	// The most bytes of state a core instance may use, not counting its
+	// memories and tables.
+	MaxCoreInstanceSize uint64
+	// The most linear memories a module may define.
+	MaxMemoriesPerModule uint32
+	// The most tables a module may define.
+	MaxTablesPerModule uint32
+	// The most elements a table may grow to.
+	TableElements uint64
+	// The most slots kept warm, with their memory still mapped, for reuse.
+	MaxUnusedWarmSlots uint32
+}
+
+// SetPoolingAllocationStrategy makes engines created with this configuration
+// allocate instances from a pool configured by `pc`.
+//
+// An error satisfying `errors.Is(err, ErrUnsupported)` is returned if the
+// library was built without the pooling allocator.
+func (cfg *Config) SetPoolingAllocationStrategy(pc PoolingAllocationConfig) error {
+	if err := checkFeature(FeaturePoolingAllocator); err != nil {
+		return err
+	}
+	ptr := cfg.ptr()
+	pool := wasmtime_pooling_allocation_config_new()
+	defer wasmtime_pooling_allocation_config_delete(pool)
+
+	if pc.TotalCoreInstances != 0 {
+		wasmtime_pooling_allocation_config_total_core_instances_set(pool, pc.TotalCoreInstances)
+	}
+	if pc.TotalMemories != 0 {
+		wasmtime_pooling_allocation_config_total_memories_set(pool, pc.TotalMemories)
+	}
+	if pc.TotalTables != 0 {
+		wasmtime_pooling_allocation_config_total_tables_set(pool, pc.TotalTables)
+	}
+	if pc.MaxMemorySize != 0 {
+		wasmtime_pooling_allocation_config_max_memory_size_set(pool, uintptr(pc.MaxMemorySize))
+	}
+	if pc.MaxCoreInstanceSize != 0 {
+		wasmtime_pooling_allocation_config_max_core_instance_size_set(pool, uintptr(pc.MaxCoreInstanceSize))
+	}
+	if pc.MaxMemoriesPerModule != 0 {
+		wasmtime_pooling_allocation_config_max_memories_per_module_set(pool, pc.MaxMemoriesPerModule)
+	}
+	if pc.MaxTablesPerModule != 0 {
+		wasmtime_pooling_allocation_config_max_tables_per_module_set(pool, pc.MaxTablesPerModule)
+	}
+	if pc.TableElements != 0 {
+		wasmtime_pooling_allocation_config_table_elements_set(pool, uintptr(pc.TableElements))
+	}
+	if pc.MaxUnusedWarmSlots != 0 {
+		wasmtime_pooling_allocation_config_max_unused_warm_slots_set(pool, pc.MaxUnusedWarmSlots)
+	}
+	// The configuration is copied, so it can be deleted afterwards.
+	wasmtime_pooling_allocation_strategy_set(uintptr(ptr), pool)
+	runtime.KeepAlive(cfg)
+	cfg.set("pooling_allocation", pc)
+	return nil
+}
@@ -0,0 +1,96 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolingAllocationStrategy(t *testing.T) {
+	if !Features()[FeaturePoolingAllocator] {
+		t.Skip("the wasmtime library was built without the pooling allocator")
+	}
+	config := NewConfig()
+	require.NoError(t, config.SetPoolingAllocationStrategy(PoolingAllocationConfig{
+		TotalCoreInstances: 2,
+		TotalMemories:      2,
+		TotalTables:        2,
+		MaxMemorySize:      1 << 20,
+	}))
+	engine := NewEngineWithConfig(config)
+	defer engine.Close()
+
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (memory (export "memory") 1)
+	    (func (export "load") (result i32) (i32.load (i32.const 0)))
+	  )
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+	linker := NewLinker(engine)
+	defer linker.Close()
+	pre, err := linker.InstantiatePre(module)
+	require.NoError(t, err)
+	defer pre.Close()
+
+	// Slots are returned to the pool as stores are closed, so far more
+	// instances than the pool holds can be created one after the other.
+	for i := 0; i < 1000; i++ {
+		store := NewStore(engine)
+		instance, err := NewInstance(store, module, nil)
+		require.NoError(t, err)
+		_, err = instance.GetFunc(store, "load").Call(store)
+		require.NoError(t, err)
+		store.Close()
+
+		store = NewStore(engine)
+		_, err = pre.Instantiate(store)
+		require.NoError(t, err)
+		store.Close()
+	}
+
+	// Instances alive at once are limited by the size of the pool.
+	var stores []*Store
+	for i := 0; i < 2; i++ {
+		store := NewStore(engine)
+		defer store.Close()
+		_, err := pre.Instantiate(store)
+		require.NoError(t, err)
+		stores = append(stores, store)
+	}
+	store := NewStore(engine)
+	defer store.Close()
+	_, err = pre.Instantiate(store)
+	// Only the stable part of wasmtime's message is matched, not the wording
+	// around the limit.
+	require.ErrorContains(t, err, "core instance limit")
+
+	// The memory size is limited too, which wasmtime may check as early as
+	// compiling the module. A slot is freed first so that the instance limit
+	// isn't what fails.
+	stores[0].Close()
+	big, err := Wat2Wasm(`(module (memory 32))`)
+	require.NoError(t, err)
+	module, err = NewModule(engine, big)
+	if err == nil {
+		store := NewStore(engine)
+		defer store.Close()
+		_, err = NewInstance(store, module, nil)
+	}
+	require.ErrorContains(t, err, "memory")
+	require.ErrorContains(t, err, "exceeds the limit")
+	require.NotContains(t, err.Error(), "core instance limit")
+}
+
+func TestPoolingAllocationFingerprint(t *testing.T) {
+	if !Features()[FeaturePoolingAllocator] {
+		t.Skip("the wasmtime library was built without the pooling allocator")
+	}
+	config := NewConfig()
+	defer config.Close()
+	fingerprint := config.Fingerprint()
+	require.NoError(t, config.SetPoolingAllocationStrategy(PoolingAllocationConfig{}))
+	require.NotEqual(t, fingerprint, config.Fingerprint())
+}
@@ -1,6 +1,7 @@
 package wasmtime
 
 import (
+	"context"
 	"reflect"
 	"runtime"
 	"sync"
@@ -15,6 +16,9 @@ type Store struct {
 	// The `Engine` that this store uses for compilation and environment
 	// settings.
 	Engine *Engine
+
+	// The `StorePool` this store was handed out by, if any.
+	pool *StorePool
 }
 
 // Storelike represents types that can be used to contextually reference a
@@ -28,8 +32,10 @@ type Storelike interface {
 	Context() unsafe.Pointer // *C.wasmtime_context_t
 }
 
+// gStoreLock serializes allocation of store indices and updates to
+// gStoreTable, while lookups in gStoreTable are lock-free.
 var gStoreLock sync.Mutex
-var gStoreMap = make(map[int]*storeData)
+var gStoreTable table[storeData]
 var gStoreSlab slab
 
 // State associated with a `Store`, currently used to propagate panic
@@ -39,11 +45,48 @@ type storeData struct {
 	engine    *Engine
 	funcNew   []funcNewEntry
 	funcWrap  []funcWrapEntry
-	lastPanic interface{}
+	lastPanic *HostPanic
+	// The Go error carried by the trap most recently returned from a host
+	// function, attached to the trap which comes out of wasm.
+	lastTrapCause error
+	// Whether host function panics are returned as traps rather than being
+	// propagated to the caller.
+	trapOnPanic bool
+	// The context passed to the innermost `Func.CallContext` currently
+	// executing in this store, handed to host functions that take one.
+	ctx context.Context
+	// Arbitrary data attached by the user with `Store.SetData`.
+	userData interface{}
+	// Whether wasm has trapped in this store, which may leave its instances
+	// in an inconsistent state.
+	trapped bool
+	// Set while a `StorePool` deletes the native store to recycle it, so that
+	// the store's index and data are kept for its next use.
+	recycling bool
+}
+
+// reset clears the state of a recycled store, keeping the capacity of its
+// function tables for the next use.
+func (data *storeData) reset() {
+	clear(data.funcNew)
+	clear(data.funcWrap)
+	*data = storeData{
+		engine:   data.engine,
+		funcNew:  data.funcNew[:0],
+		funcWrap: data.funcWrap[:0],
+	}
+}
+
+// context returns the context host functions in this store are called with.
+func (data *storeData) context() context.Context {
+	if data.ctx == nil {
+		return context.Background()
+	}
+	return data.ctx
 }
 
 type funcNewEntry struct {
-	callback func(*Caller, []Val) ([]Val, *Trap)
+	callback func(context.Context, *Caller, []Val) ([]Val, *Trap)
 	results  []*ValType
 }
 
@@ -57,10 +100,16 @@ func NewStore(engine *Engine) *Store {
 	// the store.
 	gStoreLock.Lock()
 	idx := gStoreSlab.allocate()
-	gStoreMap[idx] = &storeData{engine: engine}
+	gStoreTable.store(idx, &storeData{engine: engine})
 	gStoreLock.Unlock()
 
-	ptr := wasmtime_store_new(uintptr(engine.ptr()), idx) // C.go_store_new(engine.ptr(), C.size_t(idx))
+	return mkStore(engine, idx)
+}
+
+// mkStore creates the native store for the data at `idx` in gStoreTable.
+func mkStore(engine *Engine, idx int) *Store {
+	ptr := wasmtime_store_new(uintptr(engine.ptr()), idx, goFinalizeStorePtr)
+	runtime.KeepAlive(engine)
 	store := &Store{
 		_ptr:   unsafe.Pointer(ptr),
 		Engine: engine,
@@ -82,6 +131,10 @@ func (store *Store) ptr() uintptr {
 
 // Close will deallocate this store's state explicitly.
 //
+// This also releases the Go functions given to `NewFunc` and `WrapFunc` for
+// this store, along with the data attached with `SetData`, which are otherwise
+// retained for as long as the store is alive.
+//
 // For more information see the documentation for engine.Close()
 func (store *Store) Close() {
 	if store._ptr == nil {
@@ -100,35 +153,140 @@ func (store *Store) Context() unsafe.Pointer {
 	return unsafe.Pointer(ret)
 }
 
+// SetData attaches arbitrary user data to this store, replacing any previous
+// data.
+//
+// The data can be retrieved with `Store.Data`, or from within host functions
+// with `StoreData` given the `*Caller`.
+func (store *Store) SetData(data interface{}) {
+	getDataInStore(store).userData = data
+}
+
+// Data returns the user data attached to this store with `SetData`, or `nil`
+// if there isn't any.
+func (store *Store) Data() interface{} {
+	return getDataInStore(store).userData
+}
+
+// SetTrapOnPanic configures whether a panic in a host function is returned
+// from the `Func.Call` which entered wasm as a `*Trap` rather than being raised
+// again in the calling goroutine, which is the default.
+//
+// The `*HostPanic` carrying the panic value and stack is available from the
+// returned trap's `Panic` method.
+func (store *Store) SetTrapOnPanic(enabled bool) {
+	getDataInStore(store).trapOnPanic = enabled
+}
+
+// SetFuel sets this store's fuel to `fuel`, which wasm consumes as it
+// executes, trapping with `OutOfFuel` once it runs out.
+//
+// Returns an error if the engine isn't configured with
+// `Config.SetConsumeFuel`.
+func (store *Store) SetFuel(fuel uint64) error {
+	err := wasmtime_context_set_fuel(uintptr(store.Context()), fuel)
+	runtime.KeepAlive(store)
+	if err != 0 {
+		return mkError(unsafe.Pointer(err))
+	}
+	return nil
+}
+
+// GetFuel returns the fuel remaining in this store.
+//
+// Returns an error if the engine isn't configured with
+// `Config.SetConsumeFuel`.
+func (store *Store) GetFuel() (uint64, error) {
+	var fuel uint64
+	err := wasmtime_context_get_fuel(uintptr(store.Context()), &fuel)
+	runtime.KeepAlive(store)
+	if err != 0 {
+		return 0, mkError(unsafe.Pointer(err))
+	}
+	return fuel, nil
+}
+
+// SetEpochDeadline sets the deadline of wasm executing in this store to
+// `deadline` ticks beyond the engine's current epoch, after which it traps
+// with `Interrupt`.
+//
+// This only has an effect if the engine is configured with
+// `Config.SetEpochInterruption`. The epoch is advanced with
+// `Engine.IncrementEpoch`.
+func (store *Store) SetEpochDeadline(deadline uint64) {
+	wasmtime_context_set_epoch_deadline(uintptr(store.Context()), deadline)
+	runtime.KeepAlive(store)
+}
+
+// Limiter limits the resources the instances in this store may use: the
+// bytes a linear memory may grow to, the elements a table may grow to, and
+// the number of instances, tables and memories which may be created.
+//
+// A negative value leaves that resource unlimited, apart from the limits of
+// the engine.
+func (store *Store) Limiter(memorySize, tableElements, instances, tables, memories int64) {
+	wasmtime_store_limiter(store.ptr(), memorySize, tableElements, instances, tables, memories)
+	runtime.KeepAlive(store)
+}
+
+// StoreData returns the user data attached with `Store.SetData` to the store
+// that `store` references, typically a `*Caller` within a host function.
+//
+// The zero value of `T` is returned if no data has been attached or if it's
+// not a `T`.
+func StoreData[T any](store Storelike) T {
+	ret, _ := getDataInStore(store).userData.(T)
+	return ret
+}
+
 //export goFinalizeStore
-func goFinalizeStore(env unsafe.Pointer) {
+func goFinalizeStore(env uintptr) {
 	// When a store is finalized this is used as the finalization callback for the
 	// custom data within the store, and our finalization here will delete the
 	// store's data from the global map and deallocate its index to get reused by
 	// a future store.
-	idx := int(uintptr(env))
+	idx := int(env)
 	gStoreLock.Lock()
 	defer gStoreLock.Unlock()
-	delete(gStoreMap, idx)
+	if data := gStoreTable.load(idx); data != nil && data.recycling {
+		return
+	}
+	releaseStoreIndex(idx)
+}
+
+// releaseStoreIndex deletes the data at `idx` in gStoreTable and deallocates
+// the index. gStoreLock must be held.
+func releaseStoreIndex(idx int) {
+	gStoreTable.store(idx, nil)
 	gStoreSlab.deallocate(idx)
 }
 
 // Returns the underlying `*storeData` that this store references in Go, used
 // for inserting functions or storing panic data.
+//
+// This is on the path of every host call and so doesn't take any locks.
 func getDataInStore(store Storelike) *storeData {
 	data := uintptr(wasmtime_context_get_data(uintptr(store.Context())))
-	gStoreLock.Lock()
-	defer gStoreLock.Unlock()
-	return gStoreMap[int(data)]
+	return gStoreTable.load(int(data))
 }
 
+// gEngineFuncLock serializes allocation of engine-level function indices and
+// updates to their tables, while lookups are lock-free.
 var gEngineFuncLock sync.Mutex
-var gEngineFuncNew = make(map[int]*funcNewEntry)
+var gEngineFuncNew table[funcNewEntry]
 var gEngineFuncNewSlab slab
-var gEngineFuncWrap = make(map[int]*funcWrapEntry)
+var gEngineFuncWrap table[funcWrapEntry]
 var gEngineFuncWrapSlab slab
 
-func insertFuncNew(data *storeData, ty *FuncType, callback func(*Caller, []Val) ([]Val, *Trap)) int {
+// insertFuncNew records `callback` to be invoked through `goTrampolineNew`,
+// returning the index to pass as its `env`.
+//
+// When `data` is non-nil the callback belongs to that store and is released
+// along with it. Otherwise the callback is engine-level, such as one defined
+// in a linker, and is released when wasmtime invokes `goFinalizeFuncNew` with
+// the returned index, so `goFinalizeFuncNewPtr` must be passed to wasmtime as
+// the finalizer of the function.
+func insertFuncNew(data *storeData, ty *FuncType, callback func(context.Context, *Caller, []Val) ([]Val, *Trap)) int {
 	var idx int
 	entry := funcNewEntry{
 		callback: callback,
@@ -138,7 +296,7 @@ func insertFuncNew(data *storeData, ty *FuncType, callback func(*Caller, []Val)
 		gEngineFuncLock.Lock()
 		defer gEngineFuncLock.Unlock()
 		idx = gEngineFuncNewSlab.allocate()
-		gEngineFuncNew[idx] = &entry
+		gEngineFuncNew.store(idx, &entry)
 		idx = (idx << 1)
 	} else {
 		idx = len(data.funcNew)
@@ -150,14 +308,15 @@ func insertFuncNew(data *storeData, ty *FuncType, callback func(*Caller, []Val)
 
 func (data *storeData) getFuncNew(idx int) *funcNewEntry {
 	if idx&1 == 0 {
-		gEngineFuncLock.Lock()
-		defer gEngineFuncLock.Unlock()
-		return gEngineFuncNew[idx>>1]
+		return gEngineFuncNew.load(idx >> 1)
 	} else {
 		return &data.funcNew[idx>>1]
 	}
 }
 
+// insertFuncWrap is the same as `insertFuncNew` except for callbacks invoked
+// through `goTrampolineWrap`, where engine-level callbacks are released by
+// `goFinalizeFuncWrap`.
 func insertFuncWrap(data *storeData, callback reflect.Value) int {
 	var idx int
 	entry := funcWrapEntry{callback}
@@ -165,7 +324,7 @@ func insertFuncWrap(data *storeData, callback reflect.Value) int {
 		gEngineFuncLock.Lock()
 		defer gEngineFuncLock.Unlock()
 		idx = gEngineFuncWrapSlab.allocate()
-		gEngineFuncWrap[idx] = &entry
+		gEngineFuncWrap.store(idx, &entry)
 		idx = (idx << 1)
 	} else {
 		idx = len(data.funcWrap)
@@ -178,10 +337,32 @@ func insertFuncWrap(data *storeData, callback reflect.Value) int {
 
 func (data *storeData) getFuncWrap(idx int) *funcWrapEntry {
 	if idx&1 == 0 {
-		gEngineFuncLock.Lock()
-		defer gEngineFuncLock.Unlock()
-		return gEngineFuncWrap[idx>>1]
+		return gEngineFuncWrap.load(idx >> 1)
 	} else {
 		return &data.funcWrap[idx>>1]
 	}
 }
+
+//export goFinalizeFuncNew
+func goFinalizeFuncNew(env uintptr) {
+	// Store-level functions are released along with their store's data, so
+	// only engine-level functions need to be deallocated here.
+	idx := int(env)
+	if idx&1 == 0 {
+		gEngineFuncLock.Lock()
+		defer gEngineFuncLock.Unlock()
+		gEngineFuncNew.store(idx>>1, nil)
+		gEngineFuncNewSlab.deallocate(idx >> 1)
+	}
+}
+
+//export goFinalizeFuncWrap
+func goFinalizeFuncWrap(env uintptr) {
+	idx := int(env)
+	if idx&1 == 0 {
+		gEngineFuncLock.Lock()
+		defer gEngineFuncLock.Unlock()
+		gEngineFuncWrap.store(idx>>1, nil)
+		gEngineFuncWrapSlab.deallocate(idx >> 1)
+	}
+}
@@ -0,0 +1,177 @@
+package wasmtime
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrPoolClosed is returned when getting from a `StorePool` or an
+// `InstancePool` which has been closed.
+var ErrPoolClosed = errors.New("pool has been closed")
+
+// StoreOptions configures each store handed out by a `StorePool`.
+type StoreOptions struct {
+	// The fuel each store starts with, if non-zero. The engine must be
+	// configured with `Config.SetConsumeFuel`.
+	Fuel uint64
+	// The epoch deadline of each store, in ticks beyond the engine's epoch
+	// when the store is handed out, if non-zero. The engine must be
+	// configured with `Config.SetEpochInterruption`.
+	EpochDeadline uint64
+	// The resource limits of each store, if non-nil.
+	Limits *StoreLimits
+}
+
+// StoreLimits limits the resources the instances in a store may use, as
+// with `Store.Limiter`. Zero fields leave that resource unlimited.
+type StoreLimits struct {
+	// The most bytes a linear memory may grow to.
+	MemorySize int64
+	// The most elements a table may grow to.
+	TableElements int64
+	// The most instances, tables and memories which may be created.
+	Instances int64
+	Tables    int64
+	Memories  int64
+}
+
+// StorePool recycles stores for request-scoped execution, where a store is
+// created for every request and discarded afterwards.
+//
+// A store returned to the pool has its native store deleted, releasing its
+// instances, but keeps its index and Go-side data for the next store handed
+// out, which is then created afresh with the pool's `StoreOptions` applied.
+// Stores handed out by the pool are otherwise independent of each other, and
+// as with any store each may only be used by one goroutine at a time.
+//
+// A StorePool may be used from multiple goroutines at once.
+type StorePool struct {
+	engine *Engine
+	opts   StoreOptions
+
+	mu     sync.Mutex
+	idle   []int // indices in gStoreTable of recycled stores
+	closed bool
+}
+
+// NewStorePool creates a pool of stores for `engine`, configured by `opts`.
+func NewStorePool(engine *Engine, opts StoreOptions) *StorePool {
+	pool := &StorePool{engine: engine, opts: opts}
+	runtime.SetFinalizer(pool, func(pool *StorePool) {
+		pool.Close()
+	})
+	return pool
+}
+
+// Get returns a store from the pool, with no instances, functions or user
+// data, and with the pool's `StoreOptions` applied.
+//
+// Returns an error if the options couldn't be applied, such as fuel being set
+// for an engine which doesn't consume fuel, or `ErrPoolClosed` if the pool
+// has been closed.
+func (p *StorePool) Get() (*Store, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	idx := -1
+	if n := len(p.idle); n > 0 {
+		idx = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+	}
+	p.mu.Unlock()
+
+	if idx < 0 {
+		gStoreLock.Lock()
+		idx = gStoreSlab.allocate()
+		gStoreTable.store(idx, &storeData{engine: p.engine})
+		gStoreLock.Unlock()
+	}
+	store := mkStore(p.engine, idx)
+	store.pool = p
+
+	if err := p.configure(store); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// configure applies the pool's options to a store it's handing out.
+func (p *StorePool) configure(store *Store) error {
+	if p.opts.Fuel != 0 {
+		if err := store.SetFuel(p.opts.Fuel); err != nil {
+			return err
+		}
+	}
+	if p.opts.EpochDeadline != 0 {
+		store.SetEpochDeadline(p.opts.EpochDeadline)
+	}
+	if limits := p.opts.Limits; limits != nil {
+		unlimited := func(limit int64) int64 {
+			if limit == 0 {
+				return -1
+			}
+			return limit
+		}
+		store.Limiter(
+			unlimited(limits.MemorySize),
+			unlimited(limits.TableElements),
+			unlimited(limits.Instances),
+			unlimited(limits.Tables),
+			unlimited(limits.Memories),
+		)
+	}
+	return nil
+}
+
+// Put returns `store`, which must have come from this pool's `Get`, to the
+// pool. The store, and everything created in it, must not be used afterwards.
+//
+// Stores which are never returned are simply released like any other store.
+func (p *StorePool) Put(store *Store) {
+	if store._ptr == nil {
+		return
+	}
+	if store.pool != p {
+		panic("store doesn't belong to this StorePool")
+	}
+	idx := int(wasmtime_context_get_data(uintptr(store.Context())))
+	data := gStoreTable.load(idx)
+
+	runtime.SetFinalizer(store, nil)
+	data.recycling = true
+	wasmtime_store_delete(uintptr(store._ptr))
+	store._ptr = nil
+	data.reset()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		gStoreLock.Lock()
+		defer gStoreLock.Unlock()
+		releaseStoreIndex(idx)
+		return
+	}
+	p.idle = append(p.idle, idx)
+}
+
+// Close releases the stores kept by this pool. Stores which are still handed
+// out are released when they're returned with `Put`, or closed.
+func (p *StorePool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	runtime.SetFinalizer(p, nil)
+	p.closed = true
+	gStoreLock.Lock()
+	defer gStoreLock.Unlock()
+	for _, idx := range p.idle {
+		releaseStoreIndex(idx)
+	}
+	p.idle = nil
+}
@@ -0,0 +1,138 @@
+package wasmtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func storeIndex(store *Store) int {
+	return int(wasmtime_context_get_data(uintptr(store.Context())))
+}
+
+func TestStorePool(t *testing.T) {
+	pool := NewStorePool(NewEngine(), StoreOptions{})
+	defer pool.Close()
+
+	store, err := pool.Get()
+	require.NoError(t, err)
+	idx := storeIndex(store)
+	data := gStoreTable.load(idx)
+	WrapFunc(store, func() {})
+	store.SetData("request 1")
+	store.SetTrapOnPanic(true)
+	pool.Put(store)
+	require.Panics(t, func() { store.Context() })
+	// Putting it twice is harmless.
+	pool.Put(store)
+
+	// The next store reuses the index and data, but starts afresh.
+	store, err = pool.Get()
+	require.NoError(t, err)
+	require.Equal(t, idx, storeIndex(store))
+	require.Same(t, data, gStoreTable.load(idx))
+	require.Empty(t, data.funcWrap)
+	require.Equal(t, 1, cap(data.funcWrap))
+	require.Nil(t, store.Data())
+	require.False(t, data.trapOnPanic)
+
+	require.Panics(t, func() { NewStorePool(store.Engine, StoreOptions{}).Put(store) })
+	pool.Put(store)
+
+	// Closing the pool releases the stores it kept.
+	pool.Close()
+	require.Nil(t, gStoreTable.load(idx))
+	_, err = pool.Get()
+	require.ErrorIs(t, err, ErrPoolClosed)
+}
+
+func TestStorePoolPutAfterClose(t *testing.T) {
+	pool := NewStorePool(NewEngine(), StoreOptions{})
+	store, err := pool.Get()
+	require.NoError(t, err)
+	idx := storeIndex(store)
+	pool.Close()
+	pool.Put(store)
+	require.Nil(t, gStoreTable.load(idx))
+}
+
+func TestStorePoolOptions(t *testing.T) {
+	config := NewConfig()
+	config.SetConsumeFuel(true)
+	config.SetEpochInterruption(true)
+	engine := NewEngineWithConfig(config)
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (memory (export "memory") 1)
+	    (func (export "spin") (loop br 0))
+	    (func (export "grow") (result i32) (memory.grow (i32.const 1)))
+	  )
+	`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	pool := NewStorePool(engine, StoreOptions{
+		Fuel:          10000,
+		EpochDeadline: 1,
+		Limits:        &StoreLimits{MemorySize: 1 << 16},
+	})
+	defer pool.Close()
+	for i := 0; i < 3; i++ {
+		store, err := pool.Get()
+		require.NoError(t, err)
+		fuel, err := store.GetFuel()
+		require.NoError(t, err)
+		require.Equal(t, uint64(10000), fuel)
+
+		instance, err := NewInstance(store, module, nil)
+		require.NoError(t, err)
+		result, err := instance.GetFunc(store, "grow").Call(store)
+		require.NoError(t, err)
+		require.Equal(t, int32(-1), result)
+
+		// Fuel is refilled for each store, so every one of them runs out.
+		_, err = instance.GetFunc(store, "spin").Call(store)
+		require.ErrorIs(t, err, OutOfFuel)
+		pool.Put(store)
+	}
+
+	// Fuel can't be set for engines which don't consume it.
+	pool = NewStorePool(NewEngine(), StoreOptions{Fuel: 1})
+	defer pool.Close()
+	_, err = pool.Get()
+	require.Error(t, err)
+}
+
+func TestStoreEpochDeadline(t *testing.T) {
+	config := NewConfig()
+	config.SetEpochInterruption(true)
+	engine := NewEngineWithConfig(config)
+	wasm, err := Wat2Wasm(`(module (func (export "spin") (loop br 0)))`)
+	require.NoError(t, err)
+	module, err := NewModule(engine, wasm)
+	require.NoError(t, err)
+
+	store := NewStore(engine)
+	store.SetEpochDeadline(1)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+	engine.IncrementEpoch()
+	_, err = instance.GetFunc(store, "spin").Call(store)
+	require.ErrorIs(t, err, Interrupt)
+}
+
+func TestStoreRecycling(t *testing.T) {
+	// The store finalizer keeps the index and data of recycled stores.
+	gStoreLock.Lock()
+	idx := gStoreSlab.allocate()
+	data := &storeData{recycling: true}
+	gStoreTable.store(idx, data)
+	gStoreLock.Unlock()
+
+	goFinalizeStore(uintptr(idx))
+	require.Same(t, data, gStoreTable.load(idx))
+	data.recycling = false
+	goFinalizeStore(uintptr(idx))
+	require.Nil(t, gStoreTable.load(idx))
+}
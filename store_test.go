@@ -1,7 +1,10 @@
 package wasmtime
 
 import (
+	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestStore(t *testing.T) {
@@ -10,3 +13,65 @@ func TestStore(t *testing.T) {
 	store := NewStore(engine)
 	defer store.Close()
 }
+
+func TestStoreData(t *testing.T) {
+	type tenant struct{ name string }
+
+	store := NewStore(NewEngine())
+	require.Nil(t, store.Data())
+	require.Nil(t, StoreData[*tenant](store))
+
+	store.SetData(&tenant{name: "a"})
+	require.Equal(t, "a", store.Data().(*tenant).name)
+	require.Equal(t, "a", StoreData[*tenant](store).name)
+	require.Equal(t, "", StoreData[string](store))
+
+	var seen *tenant
+	f := WrapFunc(store, func(caller *Caller) {
+		seen = StoreData[*tenant](caller)
+	})
+	_, err := f.Call(store)
+	require.NoError(t, err)
+	require.NotNil(t, seen)
+	require.Equal(t, "a", seen.name)
+}
+
+func TestStoreCloseReleasesData(t *testing.T) {
+	store := NewStore(NewEngine())
+	WrapFunc(store, func() {})
+	idx := int(wasmtime_context_get_data(uintptr(store.Context())))
+	data := gStoreTable.load(idx)
+	require.NotNil(t, data)
+	require.Len(t, data.funcWrap, 1)
+
+	// The slot may be handed to another store as soon as it's released, so
+	// only check that it no longer holds this store's data.
+	store.Close()
+	require.NotSame(t, data, gStoreTable.load(idx))
+}
+
+func TestEngineFuncRelease(t *testing.T) {
+	idx := insertFuncWrap(nil, reflect.ValueOf(func() {}))
+	require.Equal(t, 0, idx&1, "engine-level functions have even indices")
+	entry := gEngineFuncWrap.load(idx >> 1)
+	require.NotNil(t, entry)
+	goFinalizeFuncWrap(uintptr(idx))
+	require.NotSame(t, entry, gEngineFuncWrap.load(idx>>1))
+
+	// Store-level functions are left to be released with their store.
+	data := &storeData{}
+	idx = insertFuncWrap(data, reflect.ValueOf(func() {}))
+	goFinalizeFuncWrap(uintptr(idx))
+	require.NotNil(t, data.getFuncWrap(idx))
+}
+
+func TestManyFuncs(t *testing.T) {
+	// Callbacks into Go are shared rather than created per function, which
+	// would exhaust purego's fixed supply of callbacks.
+	engine := NewEngine()
+	for i := 0; i < 3000; i++ {
+		store := NewStore(engine)
+		WrapFunc(store, func() {})
+		store.Close()
+	}
+}
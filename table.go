@@ -0,0 +1,51 @@
+package wasmtime
+
+import (
+	"sync/atomic"
+)
+
+const tableChunkBits = 8
+const tableChunkSize = 1 << tableChunkBits
+
+// table maps indices allocated from a `slab` to values, such that lookups
+// never take a lock. This keeps goroutines working with separate stores from
+// serializing on a global mutex on every host call.
+//
+// Entries live in fixed-size chunks which are never moved once allocated, and
+// the list of chunks is replaced wholesale when it grows, so readers only ever
+// perform atomic loads. Writers must be serialized by the caller, typically by
+// the lock which also guards the slab the indices come from.
+type table[T any] struct {
+	chunks atomic.Pointer[[]*tableChunk[T]]
+}
+
+type tableChunk[T any] [tableChunkSize]atomic.Pointer[T]
+
+// load returns the value stored at `idx`, or `nil` if there isn't one.
+func (t *table[T]) load(idx int) *T {
+	chunks := t.chunks.Load()
+	if chunks == nil || idx>>tableChunkBits >= len(*chunks) {
+		return nil
+	}
+	return (*chunks)[idx>>tableChunkBits][idx&(tableChunkSize-1)].Load()
+}
+
+// store sets the value at `idx`, with `nil` clearing it.
+func (t *table[T]) store(idx int, val *T) {
+	chunks := t.chunks.Load()
+	if chunks == nil || idx>>tableChunkBits >= len(*chunks) {
+		if val == nil {
+			return
+		}
+		var grown []*tableChunk[T]
+		if chunks != nil {
+			grown = append(grown, *chunks...)
+		}
+		for idx>>tableChunkBits >= len(grown) {
+			grown = append(grown, new(tableChunk[T]))
+		}
+		t.chunks.Store(&grown)
+		chunks = &grown
+	}
+	(*chunks)[idx>>tableChunkBits][idx&(tableChunkSize-1)].Store(val)
+}
@@ -0,0 +1,92 @@
+package wasmtime
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable(t *testing.T) {
+	var table table[int]
+	require.Nil(t, table.load(0))
+	require.Nil(t, table.load(1000))
+
+	a, b := 1, 2
+	table.store(0, &a)
+	table.store(3*tableChunkSize+1, &b)
+	require.Equal(t, &a, table.load(0))
+	require.Equal(t, &b, table.load(3*tableChunkSize+1))
+	require.Nil(t, table.load(1))
+	require.Nil(t, table.load(2*tableChunkSize))
+
+	table.store(0, nil)
+	require.Nil(t, table.load(0))
+	table.store(100*tableChunkSize, nil)
+	require.Nil(t, table.load(100*tableChunkSize))
+}
+
+func TestTableConcurrentGrowth(t *testing.T) {
+	var lock sync.Mutex
+	var table table[int]
+	vals := make([]int, 16*tableChunkSize)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < len(vals); i += 4 {
+				vals[i] = i
+				lock.Lock()
+				table.store(i, &vals[i])
+				lock.Unlock()
+				if got := table.load(i); got == nil || *got != i {
+					t.Errorf("wrong entry at %d", i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := range vals {
+		require.Equal(t, i, *table.load(i))
+	}
+}
+
+// The following benchmarks compare lookups in a `table` with the mutex-guarded
+// map it replaced; run them with `-cpu 1,2,4,8` to see how each scales.
+
+func BenchmarkTableLoadParallel(b *testing.B) {
+	var table table[storeData]
+	for i := 0; i < 64; i++ {
+		table.store(i, &storeData{})
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if table.load(i&63) == nil {
+				b.Fatal("missing entry")
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkMutexMapLoadParallel(b *testing.B) {
+	var lock sync.Mutex
+	m := make(map[int]*storeData)
+	for i := 0; i < 64; i++ {
+		m[i] = &storeData{}
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			lock.Lock()
+			data := m[i&63]
+			lock.Unlock()
+			if data == nil {
+				b.Fatal("missing entry")
+			}
+			i++
+		}
+	})
+}
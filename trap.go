@@ -1,6 +1,7 @@
 package wasmtime
 
 import (
+	"fmt"
 	"runtime"
 	"unsafe"
 )
@@ -11,6 +12,8 @@ type wasm_trap_t struct{}
 // Traps are bubbled up through nested instruction sequences, ultimately reducing the entire program to a single trap instruction, signalling abrupt termination.
 type Trap struct {
 	_ptr unsafe.Pointer // *C.wasm_trap_t
+	// The Go error which caused this trap, if any.
+	cause error
 }
 
 type wasm_frame_t struct{}
@@ -24,6 +27,11 @@ type Frame struct {
 }
 
 // TrapCode is the code of an instruction trap.
+//
+// Trap codes are errors, so that they can be used as sentinels matching traps
+// with `errors.Is`, and `fmt` formats them as their `Error` message, such as
+// "out of bounds memory access". `Name` returns their name instead, such as
+// "MemoryOutOfBounds".
 type TrapCode uint8
 
 const (
@@ -55,11 +63,24 @@ const (
 
 // NewTrap creates a new `Trap` with the `name` and the type provided.
 func NewTrap(message string) *Trap {
+	mustLoad()
 	ptr := wasmtime_trap_new(message, len(message))
 	runtime.KeepAlive(message)
 	return mkTrap(ptr)
 }
 
+// NewTrapFromError creates a new `Trap` carrying `err`, with the error's
+// message as the trap's message.
+//
+// When such a trap is returned from a host function it unwinds wasm and is
+// returned from the `Func.Call` that entered it, where `errors.Is` and
+// `errors.As` find `err` through the trap's `Unwrap` method.
+func NewTrapFromError(err error) *Trap {
+	trap := NewTrap(err.Error())
+	trap.cause = err
+	return trap
+}
+
 func mkTrap(ptr *wasm_trap_t) *Trap {
 	trap := &Trap{_ptr: unsafe.Pointer(ptr)}
 	runtime.SetFinalizer(trap, func(trap *Trap) {
@@ -91,32 +112,98 @@ func (t *Trap) Close() {
 
 // Message returns the message of the `Trap`
 func (t *Trap) Message() string {
-	// message := C.wasm_byte_vec_t{}
-	// C.wasm_trap_message(t.ptr(), &message)
-	// ret := C.GoStringN(message.data, C.int(message.size-1))
-	// runtime.KeepAlive(t)
-	// C.wasm_byte_vec_delete(&message)
-	// return ret
-	return "no trap message yet"
+	var message wasm_byte_vec_t
+	wasm_trap_message(uintptr(t.ptr()), &message)
+	// The message is NUL-terminated.
+	ret := string(unsafe.Slice(message.data, message.size))
+	if len(ret) > 0 && ret[len(ret)-1] == 0 {
+		ret = ret[:len(ret)-1]
+	}
+	runtime.KeepAlive(t)
+	wasm_byte_vec_delete(&message)
+	return ret
 }
 
 // Code returns the code of the `Trap` if it exists, nil otherwise.
 func (t *Trap) Code() *TrapCode {
-	// var code uint8
-	// var ret *TrapCode
-	// ok := wasmtime_trap_code(t.ptr(), &code)
-	// if ok {
-	// 	ret = (*TrapCode)(&code)
-	// }
-	// runtime.KeepAlive(t)
-	// return ret
-	return nil // no trap code yet
+	var code uint8
+	var ret *TrapCode
+	ok := wasmtime_trap_code(uintptr(t.ptr()), &code)
+	if ok {
+		ret = (*TrapCode)(&code)
+	}
+	runtime.KeepAlive(t)
+	return ret
 }
 
 func (t *Trap) Error() string {
 	return t.Message()
 }
 
+// Unwrap returns the Go error this trap was created from with
+// `NewTrapFromError`, or the `*HostPanic` which caused it, if any.
+func (t *Trap) Unwrap() error {
+	return t.cause
+}
+
+// Is reports whether this trap has the `TrapCode` given as `target`, making
+// trap codes usable as sentinel errors with `errors.Is`.
+func (t *Trap) Is(target error) bool {
+	code, ok := target.(TrapCode)
+	if !ok {
+		return false
+	}
+	ret := t.Code()
+	return ret != nil && *ret == code
+}
+
+// Panic returns the panic of the host function which caused this trap, if the
+// trap was returned because of `Store.SetTrapOnPanic`, or nil otherwise.
+func (t *Trap) Panic() *HostPanic {
+	p, _ := t.cause.(*HostPanic)
+	return p
+}
+
+// Error returns a description of the trap code, allowing trap codes to be
+// used as sentinel errors which match traps with `errors.Is`. This is also
+// what `fmt` prints for a trap code.
+func (code TrapCode) Error() string {
+	if int(code) < len(trapCodes) {
+		return trapCodes[code].message
+	}
+	return fmt.Sprintf("unknown trap code %d", uint8(code))
+}
+
+// Name returns the name of the trap code, such as "MemoryOutOfBounds",
+// suitable for labelling traps in logs and metrics.
+func (code TrapCode) Name() string {
+	if int(code) < len(trapCodes) {
+		return trapCodes[code].name
+	}
+	return fmt.Sprintf("TrapCode(%d)", uint8(code))
+}
+
+// trapCodes names and describes each trap code, indexed by the numeric value
+// of `WASMTIME_TRAP_CODE_*` in the C API of the supported wasmtime version.
+// Messages use the same wording as wasmtime.
+var trapCodes = [...]struct {
+	name    string
+	message string
+}{
+	StackOverflow:          {"StackOverflow", "call stack exhausted"},
+	MemoryOutOfBounds:      {"MemoryOutOfBounds", "out of bounds memory access"},
+	HeapMisaligned:         {"HeapMisaligned", "unaligned atomic"},
+	TableOutOfBounds:       {"TableOutOfBounds", "undefined element: out of bounds table access"},
+	IndirectCallToNull:     {"IndirectCallToNull", "uninitialized element"},
+	BadSignature:           {"BadSignature", "indirect call type mismatch"},
+	IntegerOverflow:        {"IntegerOverflow", "integer overflow"},
+	IntegerDivisionByZero:  {"IntegerDivisionByZero", "integer divide by zero"},
+	BadConversionToInteger: {"BadConversionToInteger", "invalid conversion to integer"},
+	UnreachableCodeReached: {"UnreachableCodeReached", "wasm `unreachable` instruction executed"},
+	Interrupt:              {"Interrupt", "interrupt"},
+	OutOfFuel:              {"OutOfFuel", "all fuel consumed by WebAssembly"},
+}
+
 func unwrapStrOr(s *string, other string) string {
 	if s == nil {
 		return other
@@ -125,32 +212,32 @@ func unwrapStrOr(s *string, other string) string {
 	return *s
 }
 
+type wasm_frame_vec_t struct {
+	size uintptr // C.size_t
+	data **wasm_frame_t
+}
+
 type frameList struct {
-	vec   unsafe.Pointer // C.wasm_frame_vec_t
-	owner interface{}
+	vec wasm_frame_vec_t
 }
 
 // Frames returns the wasm function frames that make up this trap
 func (t *Trap) Frames() []*Frame {
-	// frames := &frameList{owner: t}
-	// wasm_trap_trace(t.ptr(), &frames.vec)
-	// runtime.KeepAlive(t)
-	// runtime.SetFinalizer(frames, func(frames *frameList) {
-	// 	wasm_frame_vec_delete(&frames.vec)
-	// })
-
-	// ret := make([]*Frame, int(frames.vec.size))
-	// base := unsafe.Pointer(frames.vec.data)
-	// var ptr *C.wasm_frame_t
-	// for i := 0; i < int(frames.vec.size); i++ {
-	// 	ptr := *(**C.wasm_frame_t)(unsafe.Pointer(uintptr(base) + unsafe.Sizeof(ptr)*uintptr(i)))
-	// 	ret[i] = &Frame{
-	// 		_ptr:   ptr,
-	// 		_owner: frames,
-	// 	}
-	// }
-	// return ret
-	return nil // no frames yet
+	frames := &frameList{}
+	wasm_trap_trace(uintptr(t.ptr()), &frames.vec)
+	runtime.KeepAlive(t)
+	runtime.SetFinalizer(frames, func(frames *frameList) {
+		wasm_frame_vec_delete(&frames.vec)
+	})
+
+	ret := make([]*Frame, int(frames.vec.size))
+	for i, ptr := range unsafe.Slice(frames.vec.data, frames.vec.size) {
+		ret[i] = &Frame{
+			_ptr:   unsafe.Pointer(ptr),
+			_owner: frames,
+		}
+	}
+	return ret
 }
 
 func (f *Frame) ptr() *wasm_frame_t {
@@ -164,50 +251,48 @@ func (f *Frame) ptr() *wasm_frame_t {
 
 // FuncIndex returns the function index in the wasm module that this frame represents
 func (f *Frame) FuncIndex() uint32 {
-	// ret := wasm_frame_func_index(f.ptr())
-	// runtime.KeepAlive(f)
-	// return uint32(ret)
-	return 0 // no function index yet
+	ret := wasm_frame_func_index(uintptr(unsafe.Pointer(f.ptr())))
+	runtime.KeepAlive(f)
+	return ret
 }
 
 // FuncName returns the name, if available, for this frame's function
+//
+// When it isn't, `Module.FunctionNames` may still know the function by its
+// `FuncIndex`.
 func (f *Frame) FuncName() *string {
-	// ret := wasmtime_frame_func_name(f.ptr())
-	// if ret == nil {
-	// 	runtime.KeepAlive(f)
-	// 	return nil
-	// }
-	// //str := C.GoStringN(ret.data, C.int(ret.size))
-	// runtime.KeepAlive(f)
-	// return ret // &str
-	return nil // no function name yet
+	ret := wasmtime_frame_func_name(uintptr(unsafe.Pointer(f.ptr())))
+	if ret == nil {
+		runtime.KeepAlive(f)
+		return nil
+	}
+	str := string(unsafe.Slice(ret.data, ret.size))
+	runtime.KeepAlive(f)
+	return &str
 }
 
 // ModuleName returns the name, if available, for this frame's module
 func (f *Frame) ModuleName() *string {
-	// ret := wasmtime_frame_module_name(f.ptr())
-	// if ret == nil {
-	// 	runtime.KeepAlive(f)
-	// 	return nil
-	// }
-	// // str := C.GoStringN(ret.data, C.int(ret.size))
-	// runtime.KeepAlive(f)
-	// return ret // &str
-	return nil // no module name yet
+	ret := wasmtime_frame_module_name(uintptr(unsafe.Pointer(f.ptr())))
+	if ret == nil {
+		runtime.KeepAlive(f)
+		return nil
+	}
+	str := string(unsafe.Slice(ret.data, ret.size))
+	runtime.KeepAlive(f)
+	return &str
 }
 
 // ModuleOffset returns offset of this frame's instruction into the original module
 func (f *Frame) ModuleOffset() uint {
-	// ret := uint(wasm_frame_module_offset(f.ptr()))
-	// runtime.KeepAlive(f)
-	// return ret
-	return 0 // no module offset yet
+	ret := uint(wasm_frame_module_offset(uintptr(unsafe.Pointer(f.ptr()))))
+	runtime.KeepAlive(f)
+	return ret
 }
 
 // FuncOffset returns offset of this frame's instruction into the original function
 func (f *Frame) FuncOffset() uint {
-	// ret := uint(wasm_frame_func_offset(f.ptr()))
-	// runtime.KeepAlive(f)
-	// return ret
-	return 0 // no function offset yet
+	ret := uint(wasm_frame_func_offset(uintptr(unsafe.Pointer(f.ptr()))))
+	runtime.KeepAlive(f)
+	return ret
 }
@@ -0,0 +1,148 @@
+package wasmtime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrapCodeError(t *testing.T) {
+	var err error = IntegerDivisionByZero
+	require.Equal(t, "integer divide by zero", err.Error())
+	require.True(t, errors.Is(err, IntegerDivisionByZero))
+	require.False(t, errors.Is(err, IntegerOverflow))
+	require.Equal(t, "unknown trap code 200", TrapCode(200).Error())
+}
+
+func TestTrapMessage(t *testing.T) {
+	trap := NewTrap("hello")
+	require.Equal(t, "hello", trap.Message())
+	require.Equal(t, "hello", trap.Error())
+	require.Nil(t, trap.Code())
+	require.Nil(t, trap.Unwrap())
+}
+
+func TestTrapFromError(t *testing.T) {
+	trap := NewTrapFromError(io.ErrUnexpectedEOF)
+	require.Equal(t, io.ErrUnexpectedEOF.Error(), trap.Message())
+	require.True(t, errors.Is(trap, io.ErrUnexpectedEOF))
+}
+
+func TestTrapFromHostError(t *testing.T) {
+	store := NewStore(NewEngine())
+	f := WrapFunc(store, func() error {
+		return io.ErrUnexpectedEOF
+	})
+	_, err := f.Call(store)
+	require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	var trap *Trap
+	require.True(t, errors.As(err, &trap))
+
+	i32 := NewValType(KindI32)
+	g := NewFunc(store, NewFuncType(nil, []*ValType{i32}), func(*Caller, []Val) ([]Val, *Trap) {
+		return nil, NewTrapFromError(io.EOF)
+	})
+	_, err = g.Call(store)
+	require.True(t, errors.Is(err, io.EOF))
+
+	// The cause doesn't leak into later traps.
+	h := WrapFunc(store, func() *Trap {
+		return NewTrap("plain")
+	})
+	_, err = h.Call(store)
+	require.Error(t, err)
+	require.False(t, errors.Is(err, io.EOF))
+}
+
+func TestTrapCodeName(t *testing.T) {
+	require.Equal(t, "MemoryOutOfBounds", MemoryOutOfBounds.Name())
+	require.Equal(t, "TrapCode(200)", TrapCode(200).Name())
+
+	// `fmt` formats trap codes as errors, not by name.
+	require.Equal(t, "out of bounds memory access", fmt.Sprintf("%v", MemoryOutOfBounds))
+}
+
+// The codes of traps raised by the loaded library match the constants.
+func TestTrapCodeFromLibrary(t *testing.T) {
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (memory 1)
+	    (func (export "unreachable") unreachable)
+	    (func (export "div") (param i32) (result i32)
+	      (i32.div_s (i32.const 1) (local.get 0)))
+	    (func (export "load") (result i32)
+	      (i32.load (i32.const 65536)))
+	    (func (export "trunc") (result i32)
+	      (i32.trunc_f32_s (f32.const nan)))
+	    (func $recurse (export "recurse")
+	      (call $recurse))
+	  )
+	`)
+	require.NoError(t, err)
+	store := NewStore(NewEngine())
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, nil)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		export string
+		args   []interface{}
+		code   TrapCode
+	}{
+		{"unreachable", nil, UnreachableCodeReached},
+		{"div", []interface{}{0}, IntegerDivisionByZero},
+		{"load", nil, MemoryOutOfBounds},
+		{"trunc", nil, BadConversionToInteger},
+		{"recurse", nil, StackOverflow},
+	} {
+		_, err := instance.GetFunc(store, tc.export).Call(store, tc.args...)
+		var trap *Trap
+		require.True(t, errors.As(err, &trap), tc.export)
+		require.NotNil(t, trap.Code(), tc.export)
+		require.Equal(t, tc.code, *trap.Code(), tc.export)
+	}
+}
+
+func TestTrapFrames(t *testing.T) {
+	// Traps created by the host have no wasm frames.
+	require.Empty(t, NewTrap("hello").Frames())
+}
+
+func TestTrapFramesNames(t *testing.T) {
+	wasm, err := Wat2Wasm(`
+	  (module
+	    (import "" "host" (func $host))
+	    (func $inner unreachable)
+	    (func $outer (export "run") (call $inner))
+	  )
+	`)
+	require.NoError(t, err)
+	store := NewStore(NewEngine())
+	module, err := NewModule(store.Engine, wasm)
+	require.NoError(t, err)
+	instance, err := NewInstance(store, module, []AsExtern{WrapFunc(store, func() {})})
+	require.NoError(t, err)
+
+	_, err = instance.GetFunc(store, "run").Call(store)
+	var trap *Trap
+	require.True(t, errors.As(err, &trap))
+	frames := trap.Frames()
+	require.Len(t, frames, 2)
+
+	// Function indices count the imported function, and match the names in
+	// the module's name section.
+	names := module.FunctionNames()
+	require.Equal(t, map[uint32]string{0: "host", 1: "inner", 2: "outer"}, names)
+	require.Equal(t, uint32(1), frames[0].FuncIndex())
+	require.Equal(t, uint32(2), frames[1].FuncIndex())
+	for i, want := range []string{"inner", "outer"} {
+		require.Equal(t, want, names[frames[i].FuncIndex()])
+		if name := frames[i].FuncName(); name != nil {
+			require.Equal(t, want, *name)
+		}
+	}
+}
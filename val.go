@@ -10,12 +10,59 @@ var gExternrefLock sync.Mutex
 var gExternrefMap = make(map[int]interface{})
 var gExternrefSlab slab
 
+// The kinds of values stored in a `wasmtime_val_t`, which differ from the
+// `wasm_valkind_t` values used for `ValKind`.
+const (
+	wasmtimeI32       uint8 = 0 // WASMTIME_I32
+	wasmtimeI64       uint8 = 1 // WASMTIME_I64
+	wasmtimeF32       uint8 = 2 // WASMTIME_F32
+	wasmtimeF64       uint8 = 3 // WASMTIME_F64
+	wasmtimeV128      uint8 = 4 // WASMTIME_V128
+	wasmtimeFuncref   uint8 = 5 // WASMTIME_FUNCREF
+	wasmtimeExternref uint8 = 6 // WASMTIME_EXTERNREF
+	wasmtimeAnyref    uint8 = 7 // WASMTIME_ANYREF
+)
+
 type wasmtime_val_t struct {
-	kind     uint8          // C.wasm_valkind_t
-	_        [7]byte        // padding to 8 bytes
-	val      unsafe.Pointer // C.wasm_val_t
-	store_id uint32         // C.wasm_store_id_t
-	_        [4]byte        // padding to 16 bytes
+	_    [0]uint64 // aligns the struct to 8 bytes, as in C
+	kind uint8     // C.wasmtime_valkind_t
+	_    [7]byte   // padding to 8 bytes
+	of   [16]byte  // C.wasmtime_valunion_t
+}
+
+// The members of the `of` union are all stored at its start, so each one is
+// accessed by viewing the union as the member's type.
+
+func (v *wasmtime_val_t) i32() *int32 {
+	return (*int32)(unsafe.Pointer(&v.of))
+}
+
+func (v *wasmtime_val_t) i64() *int64 {
+	return (*int64)(unsafe.Pointer(&v.of))
+}
+
+func (v *wasmtime_val_t) f32() *float32 {
+	return (*float32)(unsafe.Pointer(&v.of))
+}
+
+func (v *wasmtime_val_t) f64() *float64 {
+	return (*float64)(unsafe.Pointer(&v.of))
+}
+
+func (v *wasmtime_val_t) funcref() *wasmtime_func_t {
+	return (*wasmtime_func_t)(unsafe.Pointer(&v.of))
+}
+
+func (v *wasmtime_val_t) externref() *wasmtime_externref_t {
+	return (*wasmtime_externref_t)(unsafe.Pointer(&v.of))
+}
+
+// wasmtime_externref_t is a rooted reference to an `externref`'s host data.
+// A zero `store_id` stands for a null `externref`.
+type wasmtime_externref_t struct {
+	store_id uint64 // C.wasmtime_store_id_t
+	private1 uint32
+	private2 uint32
 }
 
 // Val is a primitive numeric value.
@@ -61,37 +108,34 @@ func ValExternref(val interface{}) Val {
 	return Val{kind: uint8(KindExternref), val: val}
 }
 
-// TODO: Implement the `Val` methods to convert to/from C types
-// that are part of a `wasmtime_val_t`. which contains a union.
-// How do we do that using purego with compiling some kind of wrapper?
 func mkVal(store Storelike, src *wasmtime_val_t) Val {
 	switch src.kind {
-	case 0:
-		return ValI32(int32(go_wasmtime_val_i32_get(src)))
-	case 1:
-		return ValI64(int64(go_wasmtime_val_i64_get(src)))
-	case 2:
-		return ValF32(float32(go_wasmtime_val_f32_get(src)))
-	case 3:
-		return ValF64(float64(go_wasmtime_val_f64_get(src)))
-	case 128:
-		val := go_wasmtime_val_funcref_get(src)
+	case wasmtimeI32:
+		return ValI32(*src.i32())
+	case wasmtimeI64:
+		return ValI64(*src.i64())
+	case wasmtimeF32:
+		return ValF32(*src.f32())
+	case wasmtimeF64:
+		return ValF64(*src.f64())
+	case wasmtimeFuncref:
+		val := *src.funcref()
 		if val.store_id == 0 {
 			return ValFuncref(nil)
 		} else {
-			return ValFuncref(mkFunc(val))
+			return ValFuncref(mkFunc(&val))
+		}
+	case wasmtimeExternref:
+		val := src.externref()
+		if val.store_id == 0 {
+			return ValExternref(nil)
 		}
-		// case 129:
-		// 	val := go_wasmtime_val_externref_get(src)
-		// 	if val.store_id == 0 {
-		// 		return ValExternref(nil)
-		// 	}
-		// 	data := wasmtime_externref_data(store.Context(), &val)
-		// 	runtime.KeepAlive(store)
-
-		// 	gExternrefLock.Lock()
-		// 	defer gExternrefLock.Unlock()
-		// 	return ValExternref(gExternrefMap[int(uintptr(data))-1])
+		data := wasmtime_externref_data(uintptr(store.Context()), val)
+		runtime.KeepAlive(store)
+
+		gExternrefLock.Lock()
+		defer gExternrefLock.Unlock()
+		return ValExternref(gExternrefMap[int(data)-1])
 	}
 	panic("failed to get kind of `Val`")
 }
@@ -180,48 +224,59 @@ func (v Val) Get() interface{} {
 }
 
 func (v Val) initialize(store Storelike, ptr *wasmtime_val_t) {
-	ptr.kind = v.kind
+	ptr.of = [16]byte{}
 	switch v.kind {
 	case uint8(KindI32):
-		go_wasmtime_val_i32_set(ptr, v.val.(int32))
+		ptr.kind = wasmtimeI32
+		*ptr.i32() = v.val.(int32)
 	case uint8(KindI64):
-		go_wasmtime_val_i64_set(ptr, v.val.(int64))
+		ptr.kind = wasmtimeI64
+		*ptr.i64() = v.val.(int64)
 	case uint8(KindF32):
-		go_wasmtime_val_f32_set(ptr, v.val.(float32))
+		ptr.kind = wasmtimeF32
+		*ptr.f32() = v.val.(float32)
 	case uint8(KindF64):
-		go_wasmtime_val_f64_set(ptr, v.val.(float64))
+		ptr.kind = wasmtimeF64
+		*ptr.f64() = v.val.(float64)
 	case uint8(KindFuncref):
-		val := v.val.(*Func)
-		if val != nil {
-			go_wasmtime_val_funcref_set(ptr, uintptr(val.val))
-		} else {
-			empty := wasmtime_func_t{}
-			go_wasmtime_val_funcref_set(ptr, uintptr(unsafe.Pointer(&empty)))
+		ptr.kind = wasmtimeFuncref
+		// A null `funcref` has a zero `store_id`, which is left as-is.
+		if val := v.val.(*Func); val != nil {
+			*ptr.funcref() = *val.ptr()
 		}
 	case uint8(KindExternref):
+		ptr.kind = wasmtimeExternref
 		// If we have a non-nil value then store it in our global map
-		// of all externref values. Otherwise there's nothing for us to
-		// do since the `ref` field will already be a nil pointer.
+		// of all externref values, which wasmtime removes it from with
+		// `goFinalizeExternref` once it's no longer referenced. Otherwise
+		// there's nothing for us to do since a null `externref` has a zero
+		// `store_id`.
 		//
 		// Note that we add 1 so all non-null externref values are
 		// created with non-null pointers.
-		if v.val == nil {
-			//go_wasmtime_val_externref_set(ptr, wasmtime_externref_t{})
-		} else {
+		if v.val != nil {
 			gExternrefLock.Lock()
-			defer gExternrefLock.Unlock()
 			index := gExternrefSlab.allocate()
 			gExternrefMap[index] = v.val
-			// var ref C.wasmtime_externref_t
-			// ok := go_externref_new(store.Context(), C.size_t(index+1), &ref)
-			// runtime.KeepAlive(store)
-			// if ok {
-			// 	go_wasmtime_val_externref_set(ptr, ref)
-			// } else {
-			// 	panic("failed to create an externref")
-			// }
+			gExternrefLock.Unlock()
+
+			ok := wasmtime_externref_new(uintptr(store.Context()), uintptr(index+1), goFinalizeExternrefPtr, ptr.externref())
+			runtime.KeepAlive(store)
+			if !ok {
+				goFinalizeExternref(uintptr(index + 1))
+				panic("failed to create an externref")
+			}
 		}
 	default:
 		panic("failed to get kind of `Val`")
 	}
 }
+
+//export goFinalizeExternref
+func goFinalizeExternref(env uintptr) {
+	idx := int(env) - 1
+	gExternrefLock.Lock()
+	defer gExternrefLock.Unlock()
+	delete(gExternrefMap, idx)
+	gExternrefSlab.deallocate(idx)
+}
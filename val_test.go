@@ -0,0 +1,103 @@
+package wasmtime
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The layouts of wasmtime 33's C headers on 64-bit targets, where the size of
+// `wasmtime_valunion_t` is also checked by a static assertion in
+// `wasmtime/val.h`. The union members are all at the start of their union.
+func TestValLayout(t *testing.T) {
+	for _, tc := range []struct {
+		ty     reflect.Type
+		size   uintptr
+		fields map[string][2]uintptr // offset and size
+	}{
+		{reflect.TypeOf(wasmtime_val_t{}), 24, map[string][2]uintptr{"kind": {0, 1}, "of": {8, 16}}},
+		{reflect.TypeOf(wasmtime_func_t{}), 16, map[string][2]uintptr{"store_id": {0, 8}, "index": {8, 8}}},
+		{reflect.TypeOf(wasmtime_externref_t{}), 16, map[string][2]uintptr{"store_id": {0, 8}, "private1": {8, 4}, "private2": {12, 4}}},
+		{reflect.TypeOf(wasmtime_memory_t{}), 16, map[string][2]uintptr{"store_id": {0, 8}, "private": {8, 8}}},
+		{reflect.TypeOf(wasmtime_instance_t{}), 16, map[string][2]uintptr{"store_id": {0, 8}, "index": {8, 8}}},
+		{reflect.TypeOf(wasmtime_extern_t{}), 24, map[string][2]uintptr{"kind": {0, 1}, "of": {8, 16}}},
+	} {
+		t.Run(tc.ty.Name(), func(t *testing.T) {
+			ty := tc.ty
+			require.Equal(t, tc.size, ty.Size())
+			require.Equal(t, uintptr(8), uintptr(ty.Align()))
+			for name, layout := range tc.fields {
+				field, ok := ty.FieldByName(name)
+				require.True(t, ok, name)
+				require.Equal(t, layout[0], field.Offset, name)
+				require.Equal(t, layout[1], field.Type.Size(), name)
+			}
+		})
+	}
+
+	// Each view of `wasmtime_valunion_t` must fit in the union and start at
+	// offset 8 of `wasmtime_val_t`.
+	var v wasmtime_val_t
+	base := uintptr(unsafe.Pointer(&v))
+	for name, view := range map[string]struct {
+		ptr  unsafe.Pointer
+		size uintptr
+	}{
+		"i32":       {unsafe.Pointer(v.i32()), unsafe.Sizeof(int32(0))},
+		"i64":       {unsafe.Pointer(v.i64()), unsafe.Sizeof(int64(0))},
+		"f32":       {unsafe.Pointer(v.f32()), unsafe.Sizeof(float32(0))},
+		"f64":       {unsafe.Pointer(v.f64()), unsafe.Sizeof(float64(0))},
+		"funcref":   {unsafe.Pointer(v.funcref()), unsafe.Sizeof(wasmtime_func_t{})},
+		"externref": {unsafe.Pointer(v.externref()), unsafe.Sizeof(wasmtime_externref_t{})},
+	} {
+		require.Equal(t, uintptr(8), uintptr(view.ptr)-base, name)
+		require.LessOrEqual(t, view.size, unsafe.Sizeof(v.of), name)
+	}
+}
+
+func TestValRoundTrip(t *testing.T) {
+	for _, val := range []Val{
+		ValI32(-2),
+		ValI64(1 << 40),
+		ValF32(1.5),
+		ValF64(-2.25),
+		ValFuncref(nil),
+		ValExternref(nil),
+	} {
+		var raw wasmtime_val_t
+		val.initialize(nil, &raw)
+		require.Equal(t, val, mkVal(nil, &raw))
+	}
+
+	var raw wasmtime_val_t
+	ValI32(-1).initialize(nil, &raw)
+	require.Equal(t, wasmtimeI32, raw.kind)
+	require.Equal(t, [16]byte{0xff, 0xff, 0xff, 0xff}, raw.of)
+
+	ValF64(1).initialize(nil, &raw)
+	require.Equal(t, wasmtimeF64, raw.kind)
+	require.Equal(t, [16]byte{6: 0xf0, 7: 0x3f}, raw.of)
+
+	f := &wasmtime_func_t{store_id: 3, index: 4}
+	ValFuncref(mkFunc(f)).initialize(nil, &raw)
+	require.Equal(t, wasmtimeFuncref, raw.kind)
+	require.Equal(t, *f, *raw.funcref())
+	require.Equal(t, *f, *mkVal(nil, &raw).Funcref().ptr())
+}
+
+func TestGoFinalizeExternref(t *testing.T) {
+	gExternrefLock.Lock()
+	index := gExternrefSlab.allocate()
+	gExternrefMap[index] = "data"
+	gExternrefLock.Unlock()
+
+	goFinalizeExternref(uintptr(index + 1))
+
+	gExternrefLock.Lock()
+	defer gExternrefLock.Unlock()
+	require.NotContains(t, gExternrefMap, index)
+	require.Equal(t, index, gExternrefSlab.allocate())
+	gExternrefSlab.deallocate(index)
+}
@@ -60,6 +60,7 @@ type ValType struct {
 
 // NewValType creates a new `ValType` with the `kind` provided
 func NewValType(kind ValKind) *ValType {
+	mustLoad()
 	ptr := wasm_valtype_new(uint8(kind))
 	return mkValType(ptr, nil)
 }
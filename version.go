@@ -0,0 +1,131 @@
+package wasmtime
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// Version is the version of a wasmtime release.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// String renders this version as `major.minor.patch`.
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// The range of wasmtime major versions whose C API matches the structure
+// layouts hard-coded in this package, such as `wasmtime_val_t` and
+// `wasmtime_func_t`, as well as the numbering of `TrapCode`.
+const (
+	minSupportedMajor = 33
+	maxSupportedMajor = 33
+)
+
+// abiSymbols are symbols which must be exported by any library this package
+// can work with. Their absence means the library predates the C API this
+// package was written against.
+var abiSymbols = []string{
+	// Introduced alongside the rooted `wasmtime_val_t` layout.
+	"wasmtime_val_unroot",
+}
+
+// newerSymbols are symbols which aren't exported by any supported version.
+// Their presence means the library is too new: neither is exported by
+// wasmtime 33, and both are by wasmtime 35, whose `WASMTIME_TRAP_CODE_*`
+// numbering no longer matches `TrapCode`.
+var newerSymbols = []string{
+	"wasmtime_trap_new_code",
+	"wasmtime_config_wasm_stack_switching_set",
+}
+
+var libVersion Version
+var libVersionKnown bool
+
+// LibraryVersion returns the version of the loaded wasmtime library, loading
+// it with the default options if that hasn't happened yet.
+//
+// The version is taken from the file name of the library, or of the file it's
+// a symlink to, such as `libwasmtime.so.33.0.0`. An error is returned if the
+// library couldn't be loaded or its file name isn't versioned, such as the
+// plain `libwasmtime.so` shipped in the wasmtime release archives.
+func LibraryVersion() (Version, error) {
+	if err := Load(LoadOptions{}); err != nil {
+		return Version{}, err
+	}
+	if !libVersionKnown {
+		return Version{}, errors.New("the version of the loaded wasmtime library is unknown")
+	}
+	return libVersion, nil
+}
+
+// detectVersion determines the version of the library loaded from `path` as
+// `lib`, returning an error if the library isn't compatible with this package.
+func detectVersion(path string, lib uintptr) (Version, bool, error) {
+	return probeVersion(path, func(name string) bool {
+		_, err := symbol(lib, name)
+		return err == nil
+	})
+}
+
+// probeVersion checks the symbols reported by `has` against `abiSymbols` and
+// `newerSymbols`, which is what decides whether the library at `path` is
+// compatible. Its file name is only a hint: a library whose file name carries
+// no version is accepted, with an unknown version, while one whose file name
+// names an unsupported version is refused.
+func probeVersion(path string, has func(name string) bool) (Version, bool, error) {
+	for _, name := range abiSymbols {
+		if !has(name) {
+			return Version{}, false, fmt.Errorf(
+				"'%s' is too old: it doesn't export `%s`, wasmtime %d.x to %d.x is required",
+				path, name, minSupportedMajor, maxSupportedMajor)
+		}
+	}
+	for _, name := range newerSymbols {
+		if has(name) {
+			return Version{}, false, fmt.Errorf(
+				"'%s' is too new: it exports `%s`, wasmtime %d.x to %d.x is required",
+				path, name, minSupportedMajor, maxSupportedMajor)
+		}
+	}
+
+	version, ok := pathVersion(path)
+	if !ok {
+		return Version{}, false, nil
+	}
+	if err := checkVersion(version); err != nil {
+		return Version{}, false, fmt.Errorf("'%s': %w", path, err)
+	}
+	return version, true, nil
+}
+
+// pathVersion parses the version out of the file name of `path`, or of the
+// file it's a symlink to.
+func pathVersion(path string) (Version, bool) {
+	paths := []string{path}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		paths = append(paths, resolved)
+	}
+	for _, path := range paths {
+		parts := fileVersion(filepath.Base(path))
+		if len(parts) == 0 {
+			continue
+		}
+		parts = append(parts, 0, 0)
+		return Version{Major: parts[0], Minor: parts[1], Patch: parts[2]}, true
+	}
+	return Version{}, false
+}
+
+// checkVersion returns an error if `version` is outside the range of versions
+// supported by this package.
+func checkVersion(version Version) error {
+	if version.Major < minSupportedMajor || version.Major > maxSupportedMajor {
+		return fmt.Errorf(
+			"wasmtime %s is not supported, wasmtime %d.x to %d.x is required",
+			version, minSupportedMajor, maxSupportedMajor)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package wasmtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathVersion(t *testing.T) {
+	_, ok := pathVersion("/usr/lib/libwasmtime.so")
+	require.False(t, ok)
+
+	version, ok := pathVersion("/usr/lib/libwasmtime.so.33")
+	require.True(t, ok)
+	require.Equal(t, Version{Major: 33}, version)
+
+	version, ok = pathVersion("/usr/lib/libwasmtime.33.1.2.dylib")
+	require.True(t, ok)
+	require.Equal(t, Version{Major: 33, Minor: 1, Patch: 2}, version)
+	require.Equal(t, "33.1.2", version.String())
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "libwasmtime.so.33.0.1")
+	link := filepath.Join(dir, "libwasmtime.so")
+	require.NoError(t, os.WriteFile(target, nil, 0o644))
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks unavailable:", err)
+	}
+	version, ok = pathVersion(link)
+	require.True(t, ok)
+	require.Equal(t, Version{Major: 33, Minor: 0, Patch: 1}, version)
+}
+
+func TestCheckVersion(t *testing.T) {
+	require.NoError(t, checkVersion(Version{Major: minSupportedMajor}))
+	require.NoError(t, checkVersion(Version{Major: maxSupportedMajor, Minor: 9}))
+	require.Error(t, checkVersion(Version{Major: minSupportedMajor - 1}))
+	require.Error(t, checkVersion(Version{Major: maxSupportedMajor + 1}))
+}
+
+func TestProbeVersion(t *testing.T) {
+	exports := func(names ...string) func(string) bool {
+		return func(name string) bool {
+			for _, n := range names {
+				if n == name {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	supported := exports(abiSymbols...)
+
+	version, ok, err := probeVersion("/usr/lib/libwasmtime.so.33.0.1", supported)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, Version{Major: 33, Patch: 1}, version)
+
+	// The release archives ship an unversioned library, which is accepted on
+	// the strength of its symbols alone.
+	_, ok, err = probeVersion("/usr/lib/libwasmtime.so", supported)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, _, err = probeVersion("/usr/lib/libwasmtime.so.34", supported)
+	require.ErrorContains(t, err, "wasmtime 34.0.0 is not supported")
+
+	_, _, err = probeVersion("/usr/lib/libwasmtime.so", exports())
+	require.ErrorContains(t, err, "is too old")
+
+	newer := exports(append([]string{"wasmtime_trap_new_code"}, abiSymbols...)...)
+	_, _, err = probeVersion("/usr/lib/libwasmtime.so", newer)
+	require.ErrorContains(t, err, "is too new")
+	_, _, err = probeVersion("/usr/lib/libwasmtime.so.33.0.0", newer)
+	require.ErrorContains(t, err, "is too new")
+}
@@ -0,0 +1,532 @@
+package wasmtime
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hybridgroup/wasmtime/wasmparse"
+)
+
+// Wasm2Wat converts the binary format of WebAssembly to the text format.
+//
+// Names from the `name` custom section are used as identifiers where they're
+// present, and every definition is annotated with its index. Unlike
+// `Wat2Wasm` this is implemented in Go, so it works even when the wasmtime
+// library isn't available. Instructions from proposals beyond bulk memory,
+// reference types and tail calls, such as SIMD, are reported as errors.
+func Wasm2Wat(wasm []byte) (string, error) {
+	m, err := wasmparse.Parse(wasm)
+	if err != nil {
+		return "", err
+	}
+	p := &watPrinter{
+		m:         m,
+		funcIDs:   watIDs(m.Names.Funcs),
+		typeIDs:   watIDs(m.Names.Types),
+		tableIDs:  watIDs(m.Names.Tables),
+		memoryIDs: watIDs(m.Names.Memories),
+		globalIDs: watIDs(m.Names.Globals),
+		elemIDs:   watIDs(m.Names.Elems),
+		dataIDs:   watIDs(m.Names.Datas),
+		tagIDs:    watIDs(m.Names.Tags),
+	}
+	if err := p.module(); err != nil {
+		return "", err
+	}
+	return p.buf.String(), nil
+}
+
+// watPrinter renders a `wasmparse.Module` in the text format.
+type watPrinter struct {
+	m   *wasmparse.Module
+	buf strings.Builder
+
+	// The identifiers of each index space, including the `$`.
+	funcIDs   map[uint32]string
+	typeIDs   map[uint32]string
+	tableIDs  map[uint32]string
+	memoryIDs map[uint32]string
+	globalIDs map[uint32]string
+	elemIDs   map[uint32]string
+	dataIDs   map[uint32]string
+	tagIDs    map[uint32]string
+	// The identifiers of the locals of the function being printed.
+	localIDs map[uint32]string
+}
+
+func (p *watPrinter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&p.buf, format, args...)
+}
+
+func (p *watPrinter) module() error {
+	m := p.m
+	p.buf.WriteString("(module")
+	if m.Names.Module != nil {
+		if id := watID(*m.Names.Module); id != "" {
+			p.printf(" %s", id)
+		}
+	}
+	p.buf.WriteString("\n")
+
+	for i, typ := range m.Types {
+		p.printf("  (type%s (func%s))\n", p.decl(p.typeIDs, uint32(i)), p.signature(typ, nil))
+	}
+
+	var funcs, tables, memories, globals, tags uint32
+	for _, imp := range m.Imports {
+		p.printf("  (import %s %s ", watString([]byte(imp.Module)), watString([]byte(imp.Name)))
+		switch imp.Kind {
+		case wasmparse.ExternFunc:
+			p.printf("(func%s (type %s))", p.decl(p.funcIDs, funcs), p.ref(p.typeIDs, imp.TypeIndex))
+			funcs++
+		case wasmparse.ExternTable:
+			p.printf("(table%s %s)", p.decl(p.tableIDs, tables), p.tableType(imp.Table))
+			tables++
+		case wasmparse.ExternMemory:
+			p.printf("(memory%s %s)", p.decl(p.memoryIDs, memories), watLimits(imp.Memory))
+			memories++
+		case wasmparse.ExternGlobal:
+			p.printf("(global%s %s)", p.decl(p.globalIDs, globals), p.globalType(imp.Global))
+			globals++
+		case wasmparse.ExternTag:
+			p.printf("(tag%s (type %s))", p.decl(p.tagIDs, tags), p.ref(p.typeIDs, imp.TypeIndex))
+			tags++
+		}
+		p.buf.WriteString(")\n")
+	}
+
+	for i, typeIndex := range m.Funcs {
+		if err := p.function(funcs+uint32(i), typeIndex, m.Bodies[i]); err != nil {
+			return err
+		}
+	}
+	for i, table := range m.Tables {
+		p.printf("  (table%s %s)\n", p.decl(p.tableIDs, tables+uint32(i)), p.tableType(table))
+	}
+	for i, memory := range m.Memories {
+		p.printf("  (memory%s %s)\n", p.decl(p.memoryIDs, memories+uint32(i)), watLimits(memory))
+	}
+	for i, typeIndex := range m.Tags {
+		p.printf("  (tag%s (type %s))\n", p.decl(p.tagIDs, tags+uint32(i)), p.ref(p.typeIDs, typeIndex))
+	}
+	for i, global := range m.Globals {
+		p.printf("  (global%s %s %s)\n", p.decl(p.globalIDs, globals+uint32(i)), p.globalType(global.Type), p.instrs(global.Init))
+	}
+
+	for _, export := range m.Exports {
+		var kind, ref string
+		switch export.Kind {
+		case wasmparse.ExternFunc:
+			kind, ref = "func", p.ref(p.funcIDs, export.Index)
+		case wasmparse.ExternTable:
+			kind, ref = "table", p.ref(p.tableIDs, export.Index)
+		case wasmparse.ExternMemory:
+			kind, ref = "memory", p.ref(p.memoryIDs, export.Index)
+		case wasmparse.ExternGlobal:
+			kind, ref = "global", p.ref(p.globalIDs, export.Index)
+		case wasmparse.ExternTag:
+			kind, ref = "tag", p.ref(p.tagIDs, export.Index)
+		}
+		p.printf("  (export %s (%s %s))\n", watString([]byte(export.Name)), kind, ref)
+	}
+	if m.Start != nil {
+		p.printf("  (start %s)\n", p.ref(p.funcIDs, *m.Start))
+	}
+
+	for i, elem := range m.Elems {
+		p.printf("  (elem%s", p.decl(p.elemIDs, uint32(i)))
+		switch elem.Mode {
+		case wasmparse.ElemActive:
+			if elem.Table != 0 {
+				p.printf(" (table %s)", p.ref(p.tableIDs, elem.Table))
+			}
+			p.printf(" %s", p.offset(elem.Offset))
+		case wasmparse.ElemDeclared:
+			p.buf.WriteString(" declare")
+		}
+		if elem.UsesExprs {
+			p.printf(" %s", p.valType(elem.Type))
+			for _, expr := range elem.Exprs {
+				p.printf(" (item %s)", p.instrs(expr))
+			}
+		} else {
+			p.buf.WriteString(" func")
+			for _, f := range elem.Funcs {
+				p.printf(" %s", p.ref(p.funcIDs, f))
+			}
+		}
+		p.buf.WriteString(")\n")
+	}
+	for i, data := range m.Datas {
+		p.printf("  (data%s", p.decl(p.dataIDs, uint32(i)))
+		if data.Active {
+			if data.Memory != 0 {
+				p.printf(" (memory %s)", p.ref(p.memoryIDs, data.Memory))
+			}
+			p.printf(" %s", p.offset(data.Offset))
+		}
+		p.printf(" %s)\n", watString(data.Init))
+	}
+
+	for _, custom := range m.Customs {
+		if custom.Name == "name" {
+			continue
+		}
+		p.printf("  ;; custom section %s, size %d\n", watString([]byte(custom.Name)), len(custom.Data))
+	}
+	p.buf.WriteString(")\n")
+	return nil
+}
+
+func (p *watPrinter) function(idx, typeIndex uint32, body wasmparse.Body) error {
+	if int(typeIndex) >= len(p.m.Types) {
+		return fmt.Errorf("function %d has out of bounds type index %d", idx, typeIndex)
+	}
+	instrs, err := body.Instrs()
+	if err != nil {
+		return err
+	}
+	p.localIDs = watIDs(p.m.Names.Locals[idx])
+	typ := p.m.Types[typeIndex]
+	p.printf("  (func%s (type %s)%s\n", p.decl(p.funcIDs, idx), p.ref(p.typeIDs, typeIndex), p.signature(typ, p.localIDs))
+
+	if len(body.Locals) > 0 {
+		var locals []wasmparse.ValType
+		for _, l := range body.Locals {
+			for n := uint32(0); n < l.Count; n++ {
+				locals = append(locals, l.Type)
+			}
+		}
+		p.printf("    %s\n", strings.TrimPrefix(p.typeList("local", uint32(len(typ.Params)), locals, p.localIDs), " "))
+	}
+
+	depth := 2
+	// The last instruction is the `end` of the function itself.
+	for _, instr := range instrs[:len(instrs)-1] {
+		if instr.Op == wasmparse.OpEnd || instr.Op == wasmparse.OpElse {
+			depth--
+		}
+		p.printf("%s%s\n", strings.Repeat("  ", depth), p.instr(instr))
+		switch instr.Op {
+		case wasmparse.OpBlock, wasmparse.OpLoop, wasmparse.OpIf, wasmparse.OpElse:
+			depth++
+		}
+	}
+	p.buf.WriteString("  )\n")
+	p.localIDs = nil
+	return nil
+}
+
+// signature renders the parameters and results of `typ`, naming parameters
+// after `ids` if given.
+func (p *watPrinter) signature(typ wasmparse.FuncType, ids map[uint32]string) string {
+	s := p.typeList("param", 0, typ.Params, ids)
+	if len(typ.Results) > 0 {
+		s += " (result"
+		for _, t := range typ.Results {
+			s += " " + p.valType(t)
+		}
+		s += ")"
+	}
+	return s
+}
+
+// typeList renders `types` as a list of params or locals starting at index
+// `base`. Named ones each get their own declaration, while unnamed ones are
+// grouped together.
+func (p *watPrinter) typeList(keyword string, base uint32, types []wasmparse.ValType, ids map[uint32]string) string {
+	var s strings.Builder
+	open := false
+	for i, t := range types {
+		if id, ok := ids[base+uint32(i)]; ok {
+			if open {
+				s.WriteString(")")
+				open = false
+			}
+			fmt.Fprintf(&s, " (%s %s %s)", keyword, id, p.valType(t))
+			continue
+		}
+		if !open {
+			fmt.Fprintf(&s, " (%s", keyword)
+			open = true
+		}
+		s.WriteString(" " + p.valType(t))
+	}
+	if open {
+		s.WriteString(")")
+	}
+	return s.String()
+}
+
+func (p *watPrinter) instrs(instrs []wasmparse.Instr) string {
+	parts := make([]string, len(instrs))
+	for i, instr := range instrs {
+		parts[i] = p.instr(instr)
+	}
+	return strings.Join(parts, " ")
+}
+
+// offset renders the offset expression of an active segment.
+func (p *watPrinter) offset(instrs []wasmparse.Instr) string {
+	if len(instrs) == 1 {
+		return "(" + p.instr(instrs[0]) + ")"
+	}
+	return "(offset " + p.instrs(instrs) + ")"
+}
+
+func (p *watPrinter) instr(instr wasmparse.Instr) string {
+	s := instr.Op.Name
+	switch instr.Op.Imm {
+	case wasmparse.ImmBlock:
+		switch instr.Block.Kind {
+		case wasmparse.BlockValue:
+			s += " (result " + p.valType(instr.Block.Type) + ")"
+		case wasmparse.BlockIndex:
+			s += " (type " + p.ref(p.typeIDs, instr.Block.Index) + ")"
+		}
+	case wasmparse.ImmLabel:
+		s += " " + strconv.FormatUint(uint64(instr.Index), 10)
+	case wasmparse.ImmBrTable:
+		for _, label := range instr.Labels {
+			s += " " + strconv.FormatUint(uint64(label), 10)
+		}
+	case wasmparse.ImmFunc:
+		s += " " + p.ref(p.funcIDs, instr.Index)
+	case wasmparse.ImmCallIndirect:
+		if instr.Index2 != 0 {
+			s += " " + p.ref(p.tableIDs, instr.Index2)
+		}
+		s += " (type " + p.ref(p.typeIDs, instr.Index) + ")"
+	case wasmparse.ImmLocal:
+		s += " " + p.ref(p.localIDs, instr.Index)
+	case wasmparse.ImmGlobal:
+		s += " " + p.ref(p.globalIDs, instr.Index)
+	case wasmparse.ImmTable:
+		s += " " + p.ref(p.tableIDs, instr.Index)
+	case wasmparse.ImmMemArg:
+		if instr.Index != 0 {
+			s += " " + p.ref(p.memoryIDs, instr.Index)
+		}
+		if instr.Offset != 0 {
+			s += " offset=" + strconv.FormatUint(instr.Offset, 10)
+		}
+		if instr.Align != instr.Op.Align {
+			if instr.Align < 64 {
+				s += " align=" + strconv.FormatUint(1<<instr.Align, 10)
+			} else {
+				s += fmt.Sprintf(" (; align=2**%d ;)", instr.Align)
+			}
+		}
+	case wasmparse.ImmMemory:
+		if instr.Index != 0 {
+			s += " " + p.ref(p.memoryIDs, instr.Index)
+		}
+	case wasmparse.ImmI32:
+		s += " " + strconv.FormatInt(int64(int32(instr.Value)), 10)
+	case wasmparse.ImmI64:
+		s += " " + strconv.FormatInt(int64(instr.Value), 10)
+	case wasmparse.ImmF32:
+		s += " " + watF32(uint32(instr.Value))
+	case wasmparse.ImmF64:
+		s += " " + watF64(instr.Value)
+	case wasmparse.ImmHeapType:
+		s += " " + p.heapType(instr.Heap)
+	case wasmparse.ImmSelect:
+		s += " (result"
+		for _, t := range instr.Types {
+			s += " " + p.valType(t)
+		}
+		s += ")"
+	case wasmparse.ImmMemoryInit:
+		if instr.Index2 != 0 {
+			s += " " + p.ref(p.memoryIDs, instr.Index2)
+		}
+		s += " " + p.ref(p.dataIDs, instr.Index)
+	case wasmparse.ImmData:
+		s += " " + p.ref(p.dataIDs, instr.Index)
+	case wasmparse.ImmMemoryCopy:
+		if instr.Index != 0 || instr.Index2 != 0 {
+			s += " " + p.ref(p.memoryIDs, instr.Index) + " " + p.ref(p.memoryIDs, instr.Index2)
+		}
+	case wasmparse.ImmTableInit:
+		if instr.Index2 != 0 {
+			s += " " + p.ref(p.tableIDs, instr.Index2)
+		}
+		s += " " + p.ref(p.elemIDs, instr.Index)
+	case wasmparse.ImmElem:
+		s += " " + p.ref(p.elemIDs, instr.Index)
+	case wasmparse.ImmTableCopy:
+		s += " " + p.ref(p.tableIDs, instr.Index) + " " + p.ref(p.tableIDs, instr.Index2)
+	}
+	return s
+}
+
+// decl renders the identifier of a definition, if it has one, followed by a
+// comment with its index.
+func (p *watPrinter) decl(ids map[uint32]string, idx uint32) string {
+	if id, ok := ids[idx]; ok {
+		return fmt.Sprintf(" %s (;%d;)", id, idx)
+	}
+	return fmt.Sprintf(" (;%d;)", idx)
+}
+
+// ref renders a reference to a definition, by identifier if it has one.
+func (p *watPrinter) ref(ids map[uint32]string, idx uint32) string {
+	if id, ok := ids[idx]; ok {
+		return id
+	}
+	return strconv.FormatUint(uint64(idx), 10)
+}
+
+// valType renders `t`, referring to type indices by identifier.
+func (p *watPrinter) valType(t wasmparse.ValType) string {
+	if (t.Code == wasmparse.TypeRefNull || t.Code == wasmparse.TypeRef) && t.Heap.Code == 0 {
+		if t.Code == wasmparse.TypeRefNull {
+			return "(ref null " + p.heapType(t.Heap) + ")"
+		}
+		return "(ref " + p.heapType(t.Heap) + ")"
+	}
+	return t.String()
+}
+
+func (p *watPrinter) heapType(ht wasmparse.HeapType) string {
+	if ht.Code == 0 {
+		return p.ref(p.typeIDs, ht.Index)
+	}
+	return ht.String()
+}
+
+func (p *watPrinter) tableType(t wasmparse.TableType) string {
+	return watLimits(t.Limits) + " " + p.valType(t.Elem)
+}
+
+func (p *watPrinter) globalType(t wasmparse.GlobalType) string {
+	if t.Mutable {
+		return "(mut " + p.valType(t.Type) + ")"
+	}
+	return p.valType(t.Type)
+}
+
+func watLimits(l wasmparse.Limits) string {
+	s := ""
+	if l.Is64 {
+		s = "i64 "
+	}
+	s += strconv.FormatUint(l.Min, 10)
+	if l.HasMax {
+		s += " " + strconv.FormatUint(l.Max, 10)
+	}
+	if l.Shared {
+		s += " shared"
+	}
+	if l.PageSizeLog2 != nil {
+		s += fmt.Sprintf(" (pagesize %d)", uint64(1)<<(*l.PageSizeLog2&63))
+	}
+	return s
+}
+
+// watIDs turns the names of an index space into identifiers, making them
+// unique so that the text can be parsed again.
+func watIDs(names map[uint32]string) map[uint32]string {
+	if len(names) == 0 {
+		return nil
+	}
+	indices := make([]uint32, 0, len(names))
+	for idx := range names {
+		indices = append(indices, idx)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	ids := make(map[uint32]string, len(names))
+	used := make(map[string]bool, len(names))
+	for _, idx := range indices {
+		id := watID(names[idx])
+		if id == "" {
+			continue
+		}
+		for used[id] {
+			id = fmt.Sprintf("%s_%d", id, idx)
+		}
+		used[id] = true
+		ids[idx] = id
+	}
+	return ids
+}
+
+// watID turns `name` into an identifier, replacing the characters which
+// aren't allowed in identifiers with underscores.
+func watID(name string) string {
+	if name == "" {
+		return ""
+	}
+	var s strings.Builder
+	s.WriteByte('$')
+	for _, c := range name {
+		if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			c < 0x7f && strings.ContainsRune("!#$%&'*+-./:<=>?@\\^_`|~", c) {
+			s.WriteRune(c)
+		} else {
+			s.WriteByte('_')
+		}
+	}
+	return s.String()
+}
+
+// watString renders `b` as a string literal, escaping everything but
+// printable ASCII.
+func watString(b []byte) string {
+	var s strings.Builder
+	s.WriteByte('"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			s.WriteByte('\\')
+			s.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			s.WriteByte(c)
+		default:
+			fmt.Fprintf(&s, "\\%02x", c)
+		}
+	}
+	s.WriteByte('"')
+	return s.String()
+}
+
+func watF32(bits uint32) string {
+	f := math.Float32frombits(bits)
+	sign := ""
+	if bits>>31 != 0 {
+		sign = "-"
+	}
+	switch {
+	case f != f:
+		if payload := bits & 0x7fffff; payload != 0x400000 {
+			return fmt.Sprintf("%snan:0x%x", sign, payload)
+		}
+		return sign + "nan"
+	case math.IsInf(float64(f), 0):
+		return sign + "inf"
+	}
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func watF64(bits uint64) string {
+	f := math.Float64frombits(bits)
+	sign := ""
+	if bits>>63 != 0 {
+		sign = "-"
+	}
+	switch {
+	case f != f:
+		if payload := bits & 0xfffffffffffff; payload != 0x8000000000000 {
+			return fmt.Sprintf("%snan:0x%x", sign, payload)
+		}
+		return sign + "nan"
+	case math.IsInf(f, 0):
+		return sign + "inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -3,6 +3,8 @@ package wasmtime
 import (
 	"testing"
 
+	"github.com/hybridgroup/wasmtime/internal/wasmtest"
+	"github.com/hybridgroup/wasmtime/wasmparse"
 	"github.com/stretchr/testify/require"
 )
 
@@ -13,3 +15,194 @@ func TestWat2Wasm(t *testing.T) {
 	_, err = Wat2Wasm("___")
 	require.Error(t, err, "expected an error")
 }
+
+func TestWasm2WatEmpty(t *testing.T) {
+	wat, err := Wasm2Wat(wasmtest.Module())
+	require.NoError(t, err)
+	require.Equal(t, "(module\n)\n", wat)
+}
+
+func TestWasm2WatNames(t *testing.T) {
+	wasm := wasmtest.Module(
+		wasmtest.Section(1, wasmtest.Vec(
+			[]byte{0x60, 2, 0x7f, 0x7f, 1, 0x7f},
+			[]byte{0x60, 1, 0x7f, 0},
+		)),
+		wasmtest.Section(2, wasmtest.Vec(wasmtest.Cat(wasmtest.Name("env"), wasmtest.Name("log"), []byte{0x00, 1}))),
+		wasmtest.Section(3, wasmtest.Vec([]byte{0})),
+		wasmtest.Section(5, wasmtest.Vec([]byte{0x01, 1, 2})),
+		wasmtest.Section(6, wasmtest.Vec([]byte{0x7f, 1, 0x41, 42, 0x0b})),
+		wasmtest.Section(7, wasmtest.Vec(
+			wasmtest.Cat(wasmtest.Name("add"), []byte{0x00, 1}),
+			wasmtest.Cat(wasmtest.Name("memory"), []byte{0x02, 0}),
+		)),
+		wasmtest.Section(10, wasmtest.Vec(wasmtest.Body(0x20, 0, 0x20, 1, 0x6a, 0x0b))),
+		wasmtest.Section(11, wasmtest.Vec(wasmtest.Cat([]byte{0, 0x41, 8, 0x0b}, wasmtest.Name("hi\n\"")))),
+		wasmtest.Section(0, wasmtest.Name("name"),
+			wasmtest.Section(0, wasmtest.Name("demo")),
+			wasmtest.Section(1, wasmtest.Vec(wasmtest.Cat([]byte{0}, wasmtest.Name("log")), wasmtest.Cat([]byte{1}, wasmtest.Name("add")))),
+			wasmtest.Section(2, wasmtest.Vec(wasmtest.Cat([]byte{1}, wasmtest.Vec(wasmtest.Cat([]byte{0}, wasmtest.Name("a")), wasmtest.Cat([]byte{1}, wasmtest.Name("b")))))),
+			wasmtest.Section(7, wasmtest.Vec(wasmtest.Cat([]byte{0}, wasmtest.Name("my count")))),
+		),
+		wasmtest.Section(0, wasmtest.Name("producers"), []byte{0}),
+	)
+	wat, err := Wasm2Wat(wasm)
+	require.NoError(t, err)
+	require.Equal(t, `(module $demo
+  (type (;0;) (func (param i32 i32) (result i32)))
+  (type (;1;) (func (param i32)))
+  (import "env" "log" (func $log (;0;) (type 1)))
+  (func $add (;1;) (type 0) (param $a i32) (param $b i32) (result i32)
+    local.get $a
+    local.get $b
+    i32.add
+  )
+  (memory (;0;) 1 2)
+  (global $my_count (;0;) (mut i32) i32.const 42)
+  (export "add" (func $add))
+  (export "memory" (memory 0))
+  (data (;0;) (i32.const 8) "hi\0a\"")
+  ;; custom section "producers", size 1
+)
+`, wat)
+}
+
+func TestWasm2WatInstructions(t *testing.T) {
+	code := wasmtest.Cat(
+		[]byte{0x02, 0x40},             // block
+		[]byte{0x03, 0x7f},             // loop (result i32)
+		[]byte{0x41, 0x7f},             // i32.const -1
+		[]byte{0x04, 0x40},             // if
+		[]byte{0x0e, 2, 0, 1, 2},       // br_table 0 1 2
+		[]byte{0x05},                   // else
+		[]byte{0x0c, 1},                // br 1
+		[]byte{0x0b},                   // end
+		[]byte{0x41, 0},                // i32.const 0
+		[]byte{0x0b},                   // end
+		[]byte{0x1a},                   // drop
+		[]byte{0x0b},                   // end
+		[]byte{0x41, 4},                // i32.const 4
+		[]byte{0x28, 0, 16},            // i32.load offset=16 align=1
+		[]byte{0x1a},                   // drop
+		[]byte{0x43, 0, 0, 0xc0, 0x3f}, // f32.const 1.5
+		[]byte{0x1a},
+		[]byte{0x44, 0, 0, 0, 0, 0, 0, 0xf0, 0xff}, // f64.const -inf
+		[]byte{0x1a},
+		[]byte{0x42, 0x80, 0x80, 0x80, 0x80, 0x10}, // i64.const 4294967296
+		[]byte{0x1a},
+		[]byte{0xfc, 0x0b, 0}, // memory.fill
+		[]byte{0x0b},
+	)
+	wasm := wasmtest.Module(
+		wasmtest.Section(1, wasmtest.Vec([]byte{0x60, 0, 0})),
+		wasmtest.Section(3, wasmtest.Vec([]byte{0})),
+		wasmtest.Section(5, wasmtest.Vec([]byte{0x00, 1})),
+		wasmtest.Section(10, wasmtest.Vec(wasmtest.Cat(wasmtest.ULEB(uint64(len(code)+3)), []byte{1, 1, 0x7c}, code))),
+	)
+	wat, err := Wasm2Wat(wasm)
+	require.NoError(t, err)
+	require.Equal(t, `(module
+  (type (;0;) (func))
+  (func (;0;) (type 0)
+    (local f64)
+    block
+      loop (result i32)
+        i32.const -1
+        if
+          br_table 0 1 2
+        else
+          br 1
+        end
+        i32.const 0
+      end
+      drop
+    end
+    i32.const 4
+    i32.load offset=16 align=1
+    drop
+    f32.const 1.5
+    drop
+    f64.const -inf
+    drop
+    i64.const 4294967296
+    drop
+    memory.fill
+  )
+  (memory (;0;) 1)
+)
+`, wat)
+}
+
+func TestWasm2WatSegments(t *testing.T) {
+	wasm := wasmtest.Module(
+		wasmtest.Section(1, wasmtest.Vec([]byte{0x60, 0, 0})),
+		wasmtest.Section(3, wasmtest.Vec([]byte{0}, []byte{0})),
+		wasmtest.Section(4, wasmtest.Vec([]byte{0x70, 0x00, 2})),
+		wasmtest.Section(8, wasmtest.ULEB(1)),
+		wasmtest.Section(9, wasmtest.Vec(
+			wasmtest.Cat([]byte{0, 0x41, 0, 0x0b}, wasmtest.Vec([]byte{0}, []byte{1})),
+			wasmtest.Cat([]byte{1, 0}, wasmtest.Vec([]byte{1})),
+			wasmtest.Cat([]byte{5, 0x70}, wasmtest.Vec([]byte{0xd2, 0, 0x0b}, []byte{0xd0, 0x70, 0x0b})),
+		)),
+		wasmtest.Section(10, wasmtest.Vec(wasmtest.Body(0x0b), wasmtest.Body(0x0b))),
+		wasmtest.Section(11, wasmtest.Vec(wasmtest.Cat([]byte{1}, wasmtest.Name("\x00\xff")))),
+	)
+	wat, err := Wasm2Wat(wasm)
+	require.NoError(t, err)
+	require.Equal(t, `(module
+  (type (;0;) (func))
+  (func (;0;) (type 0)
+  )
+  (func (;1;) (type 0)
+  )
+  (table (;0;) 2 funcref)
+  (start 1)
+  (elem (;0;) (i32.const 0) func 0 1)
+  (elem (;1;) func 1)
+  (elem (;2;) funcref (item ref.func 0) (item ref.null func))
+  (data (;0;) "\00\ff")
+)
+`, wat)
+}
+
+func TestWasm2WatErrors(t *testing.T) {
+	_, err := Wasm2Wat([]byte("not wasm"))
+	require.Error(t, err)
+
+	_, err = Wasm2Wat([]byte("\x00asm\x0d\x00\x01\x00"))
+	require.ErrorIs(t, err, wasmparse.ErrComponent)
+
+	_, err = Wasm2Wat(wasmtest.Module(wasmtest.Section(1, []byte{5})))
+	require.ErrorContains(t, err, "out of bounds")
+
+	// A SIMD instruction.
+	_, err = Wasm2Wat(wasmtest.Module(
+		wasmtest.Section(1, wasmtest.Vec([]byte{0x60, 0, 0})),
+		wasmtest.Section(3, wasmtest.Vec([]byte{0})),
+		wasmtest.Section(10, wasmtest.Vec(wasmtest.Body(0xfd, 0x0c, 0x0b))),
+	))
+	require.ErrorContains(t, err, "unsupported opcode 0xfd")
+
+	// A body missing its final end.
+	_, err = Wasm2Wat(wasmtest.Module(
+		wasmtest.Section(1, wasmtest.Vec([]byte{0x60, 0, 0})),
+		wasmtest.Section(3, wasmtest.Vec([]byte{0})),
+		wasmtest.Section(10, wasmtest.Vec(wasmtest.Body(0x01))),
+	))
+	require.ErrorContains(t, err, "end")
+}
+
+func TestWatIDs(t *testing.T) {
+	ids := watIDs(map[uint32]string{0: "f", 1: "f", 2: "a b", 3: ""})
+	require.Equal(t, map[uint32]string{0: "$f", 1: "$f_1", 2: "$a_b"}, ids)
+}
+
+func TestWatFloats(t *testing.T) {
+	require.Equal(t, "nan", watF32(0x7fc00000))
+	require.Equal(t, "-nan:0x1", watF32(0xff800001))
+	require.Equal(t, "-0", watF32(0x80000000))
+	require.Equal(t, "0.1", watF32(0x3dcccccd))
+	require.Equal(t, "nan", watF64(0x7ff8000000000000))
+	require.Equal(t, "inf", watF64(0x7ff0000000000000))
+	require.Equal(t, "1e+100", watF64(0x54b249ad2594c37d))
+}
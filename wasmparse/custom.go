@@ -0,0 +1,79 @@
+package wasmparse
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CustomSections returns the custom sections of the module in `wasm`, in
+// the order they appear, without decoding the rest of the module. This works
+// for modules using features `Parse` doesn't support. The returned sections
+// refer to `wasm`.
+func CustomSections(wasm []byte) ([]CustomSection, error) {
+	r := &reader{data: wasm}
+	if magic := r.bytes(4); r.err != nil || string(magic) != "\x00asm" {
+		return nil, errors.New("not a WebAssembly binary: bad magic number")
+	}
+	r.bytes(4)
+	var customs []CustomSection
+	for !r.done() {
+		id := r.byte()
+		s := r.sub(r.u32())
+		if id == 0 {
+			name := s.name()
+			customs = append(customs, CustomSection{Name: name, Data: s.bytes(uint32(len(s.data) - s.pos))})
+			r.err = s.err
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return customs, nil
+}
+
+// Producer is a tool or language recorded in the `producers` section, with
+// its version if known.
+type Producer struct {
+	Name    string
+	Version string
+}
+
+// Producers holds the contents of the `producers` custom section, which
+// records the toolchain a module was built with.
+type Producers struct {
+	// The source languages, such as "Rust".
+	Language []Producer
+	// The tools which processed the module, such as "rustc" or "wasm-opt".
+	ProcessedBy []Producer
+	// The SDKs the module was built with, such as "Emscripten".
+	SDK []Producer
+}
+
+// ParseProducers decodes the contents of a `producers` custom section.
+// Fields other than those in `Producers` are skipped.
+func ParseProducers(data []byte) (*Producers, error) {
+	r := &reader{data: data}
+	p := &Producers{}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		field := r.name()
+		var values []Producer
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			values = append(values, Producer{Name: r.name(), Version: r.name()})
+		}
+		switch field {
+		case "language":
+			p.Language = values
+		case "processed-by":
+			p.ProcessedBy = values
+		case "sdk":
+			p.SDK = values
+		}
+	}
+	if r.err == nil && !r.done() {
+		r.fail("trailing bytes in producers section")
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed producers section: %w", r.err)
+	}
+	return p, nil
+}
@@ -0,0 +1,45 @@
+package wasmparse
+
+import (
+	"testing"
+
+	"github.com/hybridgroup/wasmtime/internal/wasmtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomSections(t *testing.T) {
+	wasm := wasmtest.Module(
+		wasmtest.Section(0, wasmtest.Name("a"), []byte{1, 2}),
+		// Code which `Parse` can't decode doesn't get in the way.
+		wasmtest.Section(10, []byte{0xfd, 0xfd}),
+		wasmtest.Section(0, wasmtest.Name("b")),
+	)
+	customs, err := CustomSections(wasm)
+	require.NoError(t, err)
+	require.Equal(t, []CustomSection{{Name: "a", Data: []byte{1, 2}}, {Name: "b", Data: []byte{}}}, customs)
+
+	_, err = CustomSections([]byte("nope"))
+	require.Error(t, err)
+	_, err = CustomSections(wasmtest.Module([]byte{0, 5, 1}))
+	require.ErrorContains(t, err, "unexpected end")
+}
+
+func TestParseProducers(t *testing.T) {
+	data := wasmtest.Vec(
+		wasmtest.Cat(wasmtest.Name("language"), wasmtest.Vec(wasmtest.Cat(wasmtest.Name("Rust"), wasmtest.Name("")))),
+		wasmtest.Cat(wasmtest.Name("processed-by"), wasmtest.Vec(
+			wasmtest.Cat(wasmtest.Name("rustc"), wasmtest.Name("1.80.0")),
+			wasmtest.Cat(wasmtest.Name("wasm-opt"), wasmtest.Name("118")),
+		)),
+		wasmtest.Cat(wasmtest.Name("other"), wasmtest.Vec()),
+	)
+	p, err := ParseProducers(data)
+	require.NoError(t, err)
+	require.Equal(t, &Producers{
+		Language:    []Producer{{Name: "Rust"}},
+		ProcessedBy: []Producer{{Name: "rustc", Version: "1.80.0"}, {Name: "wasm-opt", Version: "118"}},
+	}, p)
+
+	_, err = ParseProducers(data[:len(data)-1])
+	require.ErrorContains(t, err, "malformed producers section")
+}
@@ -0,0 +1,406 @@
+package wasmparse
+
+// Imm describes the immediates which follow an opcode, and which fields of
+// `Instr` hold them.
+type Imm int
+
+const (
+	ImmNone Imm = iota
+	// A block type in `Block`.
+	ImmBlock
+	// A label in `Index`.
+	ImmLabel
+	// The targets of `br_table` in `Labels`.
+	ImmBrTable
+	// A function index in `Index`.
+	ImmFunc
+	// A type index in `Index` and a table index in `Index2`.
+	ImmCallIndirect
+	// A local index in `Index`.
+	ImmLocal
+	// A global index in `Index`.
+	ImmGlobal
+	// A table index in `Index`.
+	ImmTable
+	// A memory index in `Index`, with `Align` and `Offset`.
+	ImmMemArg
+	// A memory index in `Index`.
+	ImmMemory
+	// A constant in `Value`, holding the bits of floats.
+	ImmI32
+	ImmI64
+	ImmF32
+	ImmF64
+	// A heap type in `Heap`.
+	ImmHeapType
+	// The result types of `select` in `Types`.
+	ImmSelect
+	// A data index in `Index` and a memory index in `Index2`.
+	ImmMemoryInit
+	// A data index in `Index`.
+	ImmData
+	// The destination memory in `Index` and source memory in `Index2`.
+	ImmMemoryCopy
+	// An element index in `Index` and a table index in `Index2`.
+	ImmTableInit
+	// An element index in `Index`.
+	ImmElem
+	// The destination table in `Index` and source table in `Index2`.
+	ImmTableCopy
+)
+
+// Op is an opcode, with its name in the text format.
+type Op struct {
+	Name string
+	Imm  Imm
+	// The log2 of the natural alignment of memory accesses.
+	Align uint32
+}
+
+// BlockKind is the kind of a `BlockType`.
+type BlockKind int
+
+const (
+	BlockEmpty BlockKind = iota
+	BlockValue
+	BlockIndex
+)
+
+// BlockType is the type of a block, which is either empty, a single result
+// in `Type` or a type index in `Index`.
+type BlockType struct {
+	Kind  BlockKind
+	Type  ValType
+	Index uint32
+}
+
+// Instr is a decoded instruction. Which fields are set depends on the
+// immediates of `Op`.
+type Instr struct {
+	Op    *Op
+	Block BlockType
+	// The index immediates, in the order they're encoded.
+	Index  uint32
+	Index2 uint32
+	// The targets of `br_table`, with the default target last.
+	Labels []uint32
+	// The memory argument, whose memory is in `Index`.
+	Align  uint32
+	Offset uint64
+	// The bits of a constant.
+	Value uint64
+	Types []ValType
+	Heap  HeapType
+}
+
+// prefixFC is the prefix of the saturating truncation, bulk memory and table
+// instructions, whose opcodes are keyed as `prefixFC<<16 | n`.
+const prefixFC = 0xfc
+
+// The opcodes which structure function bodies.
+var (
+	OpBlock = &Op{Name: "block", Imm: ImmBlock}
+	OpLoop  = &Op{Name: "loop", Imm: ImmBlock}
+	OpIf    = &Op{Name: "if", Imm: ImmBlock}
+	OpElse  = &Op{Name: "else"}
+	OpEnd   = &Op{Name: "end"}
+)
+
+var ops = map[uint32]*Op{
+	0x00: {Name: "unreachable"},
+	0x01: {Name: "nop"},
+	0x02: OpBlock,
+	0x03: OpLoop,
+	0x04: OpIf,
+	0x05: OpElse,
+	0x0b: OpEnd,
+	0x0c: {Name: "br", Imm: ImmLabel},
+	0x0d: {Name: "br_if", Imm: ImmLabel},
+	0x0e: {Name: "br_table", Imm: ImmBrTable},
+	0x0f: {Name: "return"},
+	0x10: {Name: "call", Imm: ImmFunc},
+	0x11: {Name: "call_indirect", Imm: ImmCallIndirect},
+	0x12: {Name: "return_call", Imm: ImmFunc},
+	0x13: {Name: "return_call_indirect", Imm: ImmCallIndirect},
+	0x1a: {Name: "drop"},
+	0x1b: {Name: "select"},
+	0x1c: {Name: "select", Imm: ImmSelect},
+	0x20: {Name: "local.get", Imm: ImmLocal},
+	0x21: {Name: "local.set", Imm: ImmLocal},
+	0x22: {Name: "local.tee", Imm: ImmLocal},
+	0x23: {Name: "global.get", Imm: ImmGlobal},
+	0x24: {Name: "global.set", Imm: ImmGlobal},
+	0x25: {Name: "table.get", Imm: ImmTable},
+	0x26: {Name: "table.set", Imm: ImmTable},
+
+	0x28: {Name: "i32.load", Imm: ImmMemArg, Align: 2},
+	0x29: {Name: "i64.load", Imm: ImmMemArg, Align: 3},
+	0x2a: {Name: "f32.load", Imm: ImmMemArg, Align: 2},
+	0x2b: {Name: "f64.load", Imm: ImmMemArg, Align: 3},
+	0x2c: {Name: "i32.load8_s", Imm: ImmMemArg, Align: 0},
+	0x2d: {Name: "i32.load8_u", Imm: ImmMemArg, Align: 0},
+	0x2e: {Name: "i32.load16_s", Imm: ImmMemArg, Align: 1},
+	0x2f: {Name: "i32.load16_u", Imm: ImmMemArg, Align: 1},
+	0x30: {Name: "i64.load8_s", Imm: ImmMemArg, Align: 0},
+	0x31: {Name: "i64.load8_u", Imm: ImmMemArg, Align: 0},
+	0x32: {Name: "i64.load16_s", Imm: ImmMemArg, Align: 1},
+	0x33: {Name: "i64.load16_u", Imm: ImmMemArg, Align: 1},
+	0x34: {Name: "i64.load32_s", Imm: ImmMemArg, Align: 2},
+	0x35: {Name: "i64.load32_u", Imm: ImmMemArg, Align: 2},
+	0x36: {Name: "i32.store", Imm: ImmMemArg, Align: 2},
+	0x37: {Name: "i64.store", Imm: ImmMemArg, Align: 3},
+	0x38: {Name: "f32.store", Imm: ImmMemArg, Align: 2},
+	0x39: {Name: "f64.store", Imm: ImmMemArg, Align: 3},
+	0x3a: {Name: "i32.store8", Imm: ImmMemArg, Align: 0},
+	0x3b: {Name: "i32.store16", Imm: ImmMemArg, Align: 1},
+	0x3c: {Name: "i64.store8", Imm: ImmMemArg, Align: 0},
+	0x3d: {Name: "i64.store16", Imm: ImmMemArg, Align: 1},
+	0x3e: {Name: "i64.store32", Imm: ImmMemArg, Align: 2},
+	0x3f: {Name: "memory.size", Imm: ImmMemory},
+	0x40: {Name: "memory.grow", Imm: ImmMemory},
+	0x41: {Name: "i32.const", Imm: ImmI32},
+	0x42: {Name: "i64.const", Imm: ImmI64},
+	0x43: {Name: "f32.const", Imm: ImmF32},
+	0x44: {Name: "f64.const", Imm: ImmF64},
+
+	0x45: {Name: "i32.eqz"},
+	0x46: {Name: "i32.eq"},
+	0x47: {Name: "i32.ne"},
+	0x48: {Name: "i32.lt_s"},
+	0x49: {Name: "i32.lt_u"},
+	0x4a: {Name: "i32.gt_s"},
+	0x4b: {Name: "i32.gt_u"},
+	0x4c: {Name: "i32.le_s"},
+	0x4d: {Name: "i32.le_u"},
+	0x4e: {Name: "i32.ge_s"},
+	0x4f: {Name: "i32.ge_u"},
+	0x50: {Name: "i64.eqz"},
+	0x51: {Name: "i64.eq"},
+	0x52: {Name: "i64.ne"},
+	0x53: {Name: "i64.lt_s"},
+	0x54: {Name: "i64.lt_u"},
+	0x55: {Name: "i64.gt_s"},
+	0x56: {Name: "i64.gt_u"},
+	0x57: {Name: "i64.le_s"},
+	0x58: {Name: "i64.le_u"},
+	0x59: {Name: "i64.ge_s"},
+	0x5a: {Name: "i64.ge_u"},
+	0x5b: {Name: "f32.eq"},
+	0x5c: {Name: "f32.ne"},
+	0x5d: {Name: "f32.lt"},
+	0x5e: {Name: "f32.gt"},
+	0x5f: {Name: "f32.le"},
+	0x60: {Name: "f32.ge"},
+	0x61: {Name: "f64.eq"},
+	0x62: {Name: "f64.ne"},
+	0x63: {Name: "f64.lt"},
+	0x64: {Name: "f64.gt"},
+	0x65: {Name: "f64.le"},
+	0x66: {Name: "f64.ge"},
+
+	0x67: {Name: "i32.clz"},
+	0x68: {Name: "i32.ctz"},
+	0x69: {Name: "i32.popcnt"},
+	0x6a: {Name: "i32.add"},
+	0x6b: {Name: "i32.sub"},
+	0x6c: {Name: "i32.mul"},
+	0x6d: {Name: "i32.div_s"},
+	0x6e: {Name: "i32.div_u"},
+	0x6f: {Name: "i32.rem_s"},
+	0x70: {Name: "i32.rem_u"},
+	0x71: {Name: "i32.and"},
+	0x72: {Name: "i32.or"},
+	0x73: {Name: "i32.xor"},
+	0x74: {Name: "i32.shl"},
+	0x75: {Name: "i32.shr_s"},
+	0x76: {Name: "i32.shr_u"},
+	0x77: {Name: "i32.rotl"},
+	0x78: {Name: "i32.rotr"},
+	0x79: {Name: "i64.clz"},
+	0x7a: {Name: "i64.ctz"},
+	0x7b: {Name: "i64.popcnt"},
+	0x7c: {Name: "i64.add"},
+	0x7d: {Name: "i64.sub"},
+	0x7e: {Name: "i64.mul"},
+	0x7f: {Name: "i64.div_s"},
+	0x80: {Name: "i64.div_u"},
+	0x81: {Name: "i64.rem_s"},
+	0x82: {Name: "i64.rem_u"},
+	0x83: {Name: "i64.and"},
+	0x84: {Name: "i64.or"},
+	0x85: {Name: "i64.xor"},
+	0x86: {Name: "i64.shl"},
+	0x87: {Name: "i64.shr_s"},
+	0x88: {Name: "i64.shr_u"},
+	0x89: {Name: "i64.rotl"},
+	0x8a: {Name: "i64.rotr"},
+	0x8b: {Name: "f32.abs"},
+	0x8c: {Name: "f32.neg"},
+	0x8d: {Name: "f32.ceil"},
+	0x8e: {Name: "f32.floor"},
+	0x8f: {Name: "f32.trunc"},
+	0x90: {Name: "f32.nearest"},
+	0x91: {Name: "f32.sqrt"},
+	0x92: {Name: "f32.add"},
+	0x93: {Name: "f32.sub"},
+	0x94: {Name: "f32.mul"},
+	0x95: {Name: "f32.div"},
+	0x96: {Name: "f32.min"},
+	0x97: {Name: "f32.max"},
+	0x98: {Name: "f32.copysign"},
+	0x99: {Name: "f64.abs"},
+	0x9a: {Name: "f64.neg"},
+	0x9b: {Name: "f64.ceil"},
+	0x9c: {Name: "f64.floor"},
+	0x9d: {Name: "f64.trunc"},
+	0x9e: {Name: "f64.nearest"},
+	0x9f: {Name: "f64.sqrt"},
+	0xa0: {Name: "f64.add"},
+	0xa1: {Name: "f64.sub"},
+	0xa2: {Name: "f64.mul"},
+	0xa3: {Name: "f64.div"},
+	0xa4: {Name: "f64.min"},
+	0xa5: {Name: "f64.max"},
+	0xa6: {Name: "f64.copysign"},
+
+	0xa7: {Name: "i32.wrap_i64"},
+	0xa8: {Name: "i32.trunc_f32_s"},
+	0xa9: {Name: "i32.trunc_f32_u"},
+	0xaa: {Name: "i32.trunc_f64_s"},
+	0xab: {Name: "i32.trunc_f64_u"},
+	0xac: {Name: "i64.extend_i32_s"},
+	0xad: {Name: "i64.extend_i32_u"},
+	0xae: {Name: "i64.trunc_f32_s"},
+	0xaf: {Name: "i64.trunc_f32_u"},
+	0xb0: {Name: "i64.trunc_f64_s"},
+	0xb1: {Name: "i64.trunc_f64_u"},
+	0xb2: {Name: "f32.convert_i32_s"},
+	0xb3: {Name: "f32.convert_i32_u"},
+	0xb4: {Name: "f32.convert_i64_s"},
+	0xb5: {Name: "f32.convert_i64_u"},
+	0xb6: {Name: "f32.demote_f64"},
+	0xb7: {Name: "f64.convert_i32_s"},
+	0xb8: {Name: "f64.convert_i32_u"},
+	0xb9: {Name: "f64.convert_i64_s"},
+	0xba: {Name: "f64.convert_i64_u"},
+	0xbb: {Name: "f64.promote_f32"},
+	0xbc: {Name: "i32.reinterpret_f32"},
+	0xbd: {Name: "i64.reinterpret_f64"},
+	0xbe: {Name: "f32.reinterpret_i32"},
+	0xbf: {Name: "f64.reinterpret_i64"},
+	0xc0: {Name: "i32.extend8_s"},
+	0xc1: {Name: "i32.extend16_s"},
+	0xc2: {Name: "i64.extend8_s"},
+	0xc3: {Name: "i64.extend16_s"},
+	0xc4: {Name: "i64.extend32_s"},
+
+	0xd0: {Name: "ref.null", Imm: ImmHeapType},
+	0xd1: {Name: "ref.is_null"},
+	0xd2: {Name: "ref.func", Imm: ImmFunc},
+
+	prefixFC<<16 | 0:  {Name: "i32.trunc_sat_f32_s"},
+	prefixFC<<16 | 1:  {Name: "i32.trunc_sat_f32_u"},
+	prefixFC<<16 | 2:  {Name: "i32.trunc_sat_f64_s"},
+	prefixFC<<16 | 3:  {Name: "i32.trunc_sat_f64_u"},
+	prefixFC<<16 | 4:  {Name: "i64.trunc_sat_f32_s"},
+	prefixFC<<16 | 5:  {Name: "i64.trunc_sat_f32_u"},
+	prefixFC<<16 | 6:  {Name: "i64.trunc_sat_f64_s"},
+	prefixFC<<16 | 7:  {Name: "i64.trunc_sat_f64_u"},
+	prefixFC<<16 | 8:  {Name: "memory.init", Imm: ImmMemoryInit},
+	prefixFC<<16 | 9:  {Name: "data.drop", Imm: ImmData},
+	prefixFC<<16 | 10: {Name: "memory.copy", Imm: ImmMemoryCopy},
+	prefixFC<<16 | 11: {Name: "memory.fill", Imm: ImmMemory},
+	prefixFC<<16 | 12: {Name: "table.init", Imm: ImmTableInit},
+	prefixFC<<16 | 13: {Name: "elem.drop", Imm: ImmElem},
+	prefixFC<<16 | 14: {Name: "table.copy", Imm: ImmTableCopy},
+	prefixFC<<16 | 15: {Name: "table.grow", Imm: ImmTable},
+	prefixFC<<16 | 16: {Name: "table.size", Imm: ImmTable},
+	prefixFC<<16 | 17: {Name: "table.fill", Imm: ImmTable},
+}
+
+// instr decodes the next instruction.
+func (r *reader) instr() Instr {
+	code := uint32(r.byte())
+	if code == prefixFC {
+		code = prefixFC<<16 | r.u32()
+	}
+	if r.err != nil {
+		return Instr{Op: OpEnd}
+	}
+	op, ok := ops[code]
+	if !ok {
+		if code > 0xff {
+			r.fail("unsupported opcode 0x%x 0x%x", code>>16, code&0xffff)
+		} else {
+			r.fail("unsupported opcode 0x%x", code)
+		}
+		return Instr{Op: OpEnd}
+	}
+
+	instr := Instr{Op: op}
+	switch op.Imm {
+	case ImmBlock:
+		instr.Block = r.blockType()
+	case ImmLabel, ImmFunc, ImmLocal, ImmGlobal, ImmTable,
+		ImmData, ImmElem:
+		instr.Index = r.u32()
+	case ImmBrTable:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			instr.Labels = append(instr.Labels, r.u32())
+		}
+		instr.Labels = append(instr.Labels, r.u32())
+	case ImmCallIndirect, ImmMemoryInit, ImmMemoryCopy,
+		ImmTableInit, ImmTableCopy:
+		instr.Index = r.u32()
+		instr.Index2 = r.u32()
+	case ImmMemArg:
+		flags := r.u32()
+		if flags&0x40 != 0 {
+			instr.Index = r.u32()
+		}
+		instr.Align = flags &^ 0x40
+		instr.Offset = r.u64()
+	case ImmMemory:
+		instr.Index = r.u32()
+	case ImmI32:
+		instr.Value = uint64(r.s32())
+	case ImmI64:
+		instr.Value = uint64(r.s64())
+	case ImmF32:
+		b := r.bytes(4)
+		for i := len(b) - 1; i >= 0; i-- {
+			instr.Value = instr.Value<<8 | uint64(b[i])
+		}
+	case ImmF64:
+		b := r.bytes(8)
+		for i := len(b) - 1; i >= 0; i-- {
+			instr.Value = instr.Value<<8 | uint64(b[i])
+		}
+	case ImmHeapType:
+		instr.Heap = r.heapType()
+	case ImmSelect:
+		instr.Types = r.valTypes()
+	}
+	if r.err != nil {
+		return Instr{Op: OpEnd}
+	}
+	return instr
+}
+
+func (r *reader) blockType() BlockType {
+	if r.pos < len(r.data) {
+		switch b := r.data[r.pos]; {
+		case b == 0x40:
+			r.pos++
+			return BlockType{Kind: BlockEmpty}
+		case b == TypeI32 || b == TypeI64 || b == TypeF32 || b == TypeF64 || b == TypeV128 ||
+			b == TypeRef || b == TypeRefNull || heapTypeNames[b] != "":
+			return BlockType{Kind: BlockValue, Type: r.valType()}
+		}
+	}
+	index := r.s33()
+	if index < 0 {
+		r.fail("malformed block type")
+	}
+	return BlockType{Kind: BlockIndex, Index: uint32(index)}
+}
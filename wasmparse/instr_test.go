@@ -0,0 +1,63 @@
+package wasmparse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, code ...byte) Instr {
+	r := &reader{data: code}
+	instr := r.instr()
+	require.NoError(t, r.err)
+	require.True(t, r.done(), "trailing bytes")
+	return instr
+}
+
+func TestInstr(t *testing.T) {
+	instr := decode(t, 0x0e, 2, 3, 4, 5)
+	require.Equal(t, "br_table", instr.Op.Name)
+	require.Equal(t, []uint32{3, 4, 5}, instr.Labels)
+
+	instr = decode(t, 0x11, 2, 1)
+	require.Equal(t, ImmCallIndirect, instr.Op.Imm)
+	require.Equal(t, uint32(2), instr.Index)
+	require.Equal(t, uint32(1), instr.Index2)
+
+	instr = decode(t, 0x29, 0x43, 1, 8)
+	require.Equal(t, "i64.load", instr.Op.Name)
+	require.Equal(t, uint32(3), instr.Align)
+	require.Equal(t, uint32(1), instr.Index)
+	require.Equal(t, uint64(8), instr.Offset)
+
+	instr = decode(t, 0x44, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f)
+	require.Equal(t, uint64(0x3ff0000000000000), instr.Value)
+
+	instr = decode(t, 0x02, 0x40)
+	require.Same(t, OpBlock, instr.Op)
+	require.Equal(t, BlockType{Kind: BlockEmpty}, instr.Block)
+	instr = decode(t, 0x03, 0x70)
+	require.Equal(t, BlockType{Kind: BlockValue, Type: ValType{Code: TypeRefNull, Heap: HeapType{Code: 0x70}}}, instr.Block)
+	instr = decode(t, 0x04, 3)
+	require.Equal(t, BlockType{Kind: BlockIndex, Index: 3}, instr.Block)
+
+	instr = decode(t, 0xd0, 0x6f)
+	require.Equal(t, "extern", instr.Heap.String())
+
+	instr = decode(t, 0xfc, 0x0c, 1, 2)
+	require.Equal(t, "table.init", instr.Op.Name)
+	require.Equal(t, uint32(1), instr.Index)
+	require.Equal(t, uint32(2), instr.Index2)
+
+	r := &reader{data: []byte{0xfd, 0x0c}}
+	r.instr()
+	require.ErrorContains(t, r.err, "unsupported opcode 0xfd")
+}
+
+func TestValTypeString(t *testing.T) {
+	require.Equal(t, "i32", ValType{Code: TypeI32}.String())
+	require.Equal(t, "funcref", ValType{Code: TypeRefNull, Heap: HeapType{Code: 0x70}}.String())
+	require.Equal(t, "(ref null any)", ValType{Code: TypeRefNull, Heap: HeapType{Code: 0x6e}}.String())
+	require.Equal(t, "(ref 3)", ValType{Code: TypeRef, Heap: HeapType{Index: 3}}.String())
+	require.Equal(t, "func", ExternFunc.String())
+}
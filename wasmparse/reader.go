@@ -0,0 +1,327 @@
+package wasmparse
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// reader reads the encodings of the binary format. The first error is
+// sticky: once a read fails every later read returns a zero value, so callers
+// only need to check `err` at convenient points.
+type reader struct {
+	data []byte
+	pos  int
+	// The offset of `data` within the module, for error messages.
+	base int
+	err  error
+}
+
+func (r *reader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("offset 0x%x: %s", r.base+r.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *reader) done() bool {
+	return r.err != nil || r.pos >= len(r.data)
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.fail("unexpected end")
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n uint32) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(n) > uint64(len(r.data)-r.pos) {
+		r.fail("unexpected end")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+// leb reads a LEB128 integer of at most `bits` bits, sign-extending it if
+// `signed` is set.
+func (r *reader) leb(bits uint, signed bool) uint64 {
+	var result uint64
+	var shift uint
+	for {
+		b := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		result |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if signed && shift < 64 && b&0x40 != 0 {
+				result |= ^uint64(0) << shift
+			}
+			if !signed && bits < 64 && result>>bits != 0 {
+				r.fail("integer too large")
+			}
+			return result
+		}
+		if shift >= bits {
+			r.fail("integer representation too long")
+			return 0
+		}
+	}
+}
+
+func (r *reader) u32() uint32 {
+	return uint32(r.leb(32, false))
+}
+
+func (r *reader) u64() uint64 {
+	return r.leb(64, false)
+}
+
+func (r *reader) s32() int32 {
+	return int32(r.leb(32, true))
+}
+
+func (r *reader) s33() int64 {
+	return int64(r.leb(33, true))
+}
+
+func (r *reader) s64() int64 {
+	return int64(r.leb(64, true))
+}
+
+func (r *reader) name() string {
+	b := r.bytes(r.u32())
+	if r.err == nil && !utf8.Valid(b) {
+		r.fail("malformed UTF-8 encoding")
+	}
+	return string(b)
+}
+
+// count reads the length of a vector, guarding against lengths which can't
+// possibly fit in the remaining input since each element takes at least one
+// byte.
+func (r *reader) count() uint32 {
+	n := r.u32()
+	if r.err == nil && uint64(n) > uint64(len(r.data)-r.pos) {
+		r.fail("vector length %d out of bounds", n)
+		return 0
+	}
+	return n
+}
+
+// sub returns a reader over the next `n` bytes, and skips past them.
+func (r *reader) sub(n uint32) *reader {
+	base := r.base + r.pos
+	return &reader{data: r.bytes(n), base: base, err: r.err}
+}
+
+func (r *reader) heapType() HeapType {
+	ht := r.s33()
+	if ht >= 0 {
+		return HeapType{Index: uint32(ht)}
+	}
+	code := byte(ht & 0x7f)
+	if _, ok := heapTypeNames[code]; !ok {
+		r.fail("unknown heap type 0x%x", code)
+	}
+	return HeapType{Code: code}
+}
+
+func (r *reader) valType() ValType {
+	code := r.byte()
+	switch code {
+	case TypeI32, TypeI64, TypeF32, TypeF64, TypeV128:
+		return ValType{Code: code}
+	case TypeRefNull, TypeRef:
+		return ValType{Code: code, Heap: r.heapType()}
+	}
+	if _, ok := heapTypeNames[code]; ok {
+		// The abbreviations of nullable references, such as `funcref`.
+		return ValType{Code: TypeRefNull, Heap: HeapType{Code: code}}
+	}
+	r.fail("unknown value type 0x%x", code)
+	return ValType{}
+}
+
+func (r *reader) valTypes() []ValType {
+	var types []ValType
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		types = append(types, r.valType())
+	}
+	return types
+}
+
+func (r *reader) funcType() FuncType {
+	switch form := r.byte(); form {
+	case 0x60:
+	case 0x4e, 0x50, 0x4f, 0x5f, 0x5e:
+		r.fail("GC types are not supported")
+	default:
+		r.fail("unknown type form 0x%x", form)
+	}
+	return FuncType{Params: r.valTypes(), Results: r.valTypes()}
+}
+
+func (r *reader) limits() Limits {
+	flags := r.byte()
+	if flags&^0x0f != 0 {
+		r.fail("unknown limits flags 0x%x", flags)
+	}
+	l := Limits{HasMax: flags&0x01 != 0, Shared: flags&0x02 != 0, Is64: flags&0x04 != 0}
+	if l.Is64 {
+		l.Min = r.u64()
+		if l.HasMax {
+			l.Max = r.u64()
+		}
+	} else {
+		l.Min = uint64(r.u32())
+		if l.HasMax {
+			l.Max = uint64(r.u32())
+		}
+	}
+	if flags&0x08 != 0 {
+		pageSizeLog2 := r.u32()
+		l.PageSizeLog2 = &pageSizeLog2
+	}
+	return l
+}
+
+func (r *reader) tableType() TableType {
+	elem := r.valType()
+	return TableType{Elem: elem, Limits: r.limits()}
+}
+
+func (r *reader) globalType() GlobalType {
+	typ := r.valType()
+	switch mut := r.byte(); mut {
+	case 0:
+		return GlobalType{Type: typ}
+	case 1:
+		return GlobalType{Type: typ, Mutable: true}
+	default:
+		r.fail("malformed mutability 0x%x", mut)
+		return GlobalType{}
+	}
+}
+
+func (r *reader) tagType() {
+	if attr := r.byte(); attr != 0 {
+		r.fail("unknown tag attribute 0x%x", attr)
+	}
+}
+
+// expr reads a constant expression, returning its instructions without the
+// final `end`.
+func (r *reader) expr() []Instr {
+	var instrs []Instr
+	for r.err == nil {
+		instr := r.instr()
+		if instr.Op == OpEnd {
+			break
+		}
+		instrs = append(instrs, instr)
+	}
+	return instrs
+}
+
+func (r *reader) elemSegment() Elem {
+	flags := r.u32()
+	if flags > 7 {
+		r.fail("unknown element segment flags %d", flags)
+		return Elem{}
+	}
+	e := Elem{UsesExprs: flags&0x04 != 0}
+	switch {
+	case flags&0x01 == 0:
+		e.Mode = ElemActive
+		if flags&0x02 != 0 {
+			e.Table = r.u32()
+		}
+		e.Offset = r.expr()
+	case flags&0x02 == 0:
+		e.Mode = ElemPassive
+	default:
+		e.Mode = ElemDeclared
+	}
+	// Segments of encoding 0 and 4 are implicitly of type funcref.
+	e.Type = ValType{Code: TypeRefNull, Heap: HeapType{Code: 0x70}}
+	if flags&0x03 != 0 {
+		if e.UsesExprs {
+			e.Type = r.valType()
+		} else if kind := r.byte(); kind != 0 {
+			r.fail("unknown element kind 0x%x", kind)
+		}
+	}
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		if e.UsesExprs {
+			e.Exprs = append(e.Exprs, r.expr())
+		} else {
+			e.Funcs = append(e.Funcs, r.u32())
+		}
+	}
+	return e
+}
+
+func (r *reader) dataSegment() Data {
+	var d Data
+	switch flags := r.u32(); flags {
+	case 0:
+		d.Active = true
+		d.Offset = r.expr()
+	case 1:
+	case 2:
+		d.Active = true
+		d.Memory = r.u32()
+		d.Offset = r.expr()
+	default:
+		r.fail("unknown data segment flags %d", flags)
+	}
+	d.Init = r.bytes(r.u32())
+	return d
+}
+
+func (r *reader) body() Body {
+	var b Body
+	total := uint64(0)
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		locals := Locals{Count: r.u32(), Type: r.valType()}
+		total += uint64(locals.Count)
+		if total > MaxLocals {
+			r.fail("too many locals")
+		}
+		b.Locals = append(b.Locals, locals)
+	}
+	b.Offset = r.base + r.pos
+	b.Code = r.data[r.pos:]
+	r.pos = len(r.data)
+	return b
+}
+
+func (r *reader) nameMap() map[uint32]string {
+	names := make(map[uint32]string)
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		idx := r.u32()
+		names[idx] = r.name()
+	}
+	return names
+}
+
+func (r *reader) indirectNameMap() map[uint32]map[uint32]string {
+	names := make(map[uint32]map[uint32]string)
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		idx := r.u32()
+		names[idx] = r.nameMap()
+	}
+	return names
+}
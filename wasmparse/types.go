@@ -0,0 +1,261 @@
+package wasmparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The encodings of the number and vector value types, and of the two
+// reference type constructors.
+const (
+	TypeI32  = 0x7f
+	TypeI64  = 0x7e
+	TypeF32  = 0x7d
+	TypeF64  = 0x7c
+	TypeV128 = 0x7b
+
+	TypeRefNull = 0x63
+	TypeRef     = 0x64
+)
+
+// HeapType is either an abstract heap type, such as 0x70 for `func`, or the
+// index of a type if `Code` is zero.
+type HeapType struct {
+	Code  byte
+	Index uint32
+}
+
+// String returns the name of an abstract heap type, such as "func", or the
+// type index.
+func (h HeapType) String() string {
+	if h.Code == 0 {
+		return fmt.Sprint(h.Index)
+	}
+	return heapTypeNames[h.Code]
+}
+
+// heapTypeNames names the abstract heap types by their encoding.
+var heapTypeNames = map[byte]string{
+	0x70: "func",
+	0x6f: "extern",
+	0x6e: "any",
+	0x6d: "eq",
+	0x6c: "i31",
+	0x6b: "struct",
+	0x6a: "array",
+	0x69: "exn",
+	0x71: "none",
+	0x72: "noextern",
+	0x73: "nofunc",
+	0x74: "noexn",
+}
+
+// ValType is a value type, such as `TypeI32`. Reference types have a `Code`
+// of `TypeRefNull` or `TypeRef` with their heap type in `Heap`, including
+// abbreviations such as `funcref`.
+type ValType struct {
+	Code byte
+	Heap HeapType
+}
+
+// String returns the value type in the text format, such as "i32" or
+// "funcref".
+func (t ValType) String() string {
+	switch t.Code {
+	case TypeI32:
+		return "i32"
+	case TypeI64:
+		return "i64"
+	case TypeF32:
+		return "f32"
+	case TypeF64:
+		return "f64"
+	case TypeV128:
+		return "v128"
+	case TypeRefNull:
+		switch t.Heap.Code {
+		case 0x70:
+			return "funcref"
+		case 0x6f:
+			return "externref"
+		}
+		return "(ref null " + t.Heap.String() + ")"
+	}
+	return "(ref " + t.Heap.String() + ")"
+}
+
+// FuncType is the signature of a function.
+type FuncType struct {
+	Params  []ValType
+	Results []ValType
+}
+
+func (t FuncType) String() string {
+	var s strings.Builder
+	s.WriteString("(func")
+	if len(t.Params) > 0 {
+		s.WriteString(" (param")
+		for _, p := range t.Params {
+			s.WriteString(" " + p.String())
+		}
+		s.WriteString(")")
+	}
+	if len(t.Results) > 0 {
+		s.WriteString(" (result")
+		for _, r := range t.Results {
+			s.WriteString(" " + r.String())
+		}
+		s.WriteString(")")
+	}
+	s.WriteString(")")
+	return s.String()
+}
+
+// Limits are the size limits of a memory or table, along with the other
+// flags encoded with them.
+type Limits struct {
+	Min    uint64
+	Max    uint64
+	HasMax bool
+	Shared bool
+	Is64   bool
+	// The log2 of a custom page size, if set.
+	PageSizeLog2 *uint32
+}
+
+// TableType is the type of a table.
+type TableType struct {
+	Elem   ValType
+	Limits Limits
+}
+
+// GlobalType is the type of a global.
+type GlobalType struct {
+	Type    ValType
+	Mutable bool
+}
+
+// ExternKind is the kind of an import or export.
+type ExternKind byte
+
+const (
+	ExternFunc   ExternKind = 0x00
+	ExternTable  ExternKind = 0x01
+	ExternMemory ExternKind = 0x02
+	ExternGlobal ExternKind = 0x03
+	ExternTag    ExternKind = 0x04
+)
+
+// String returns the keyword of the kind in the text format, such as "func".
+func (k ExternKind) String() string {
+	switch k {
+	case ExternFunc:
+		return "func"
+	case ExternTable:
+		return "table"
+	case ExternMemory:
+		return "memory"
+	case ExternGlobal:
+		return "global"
+	case ExternTag:
+		return "tag"
+	}
+	return fmt.Sprintf("ExternKind(%d)", byte(k))
+}
+
+// Import is an entry of the import section. Which of the type fields is set
+// depends on its `Kind`.
+type Import struct {
+	Module string
+	Name   string
+	Kind   ExternKind
+	// The type index of functions and tags.
+	TypeIndex uint32
+	Table     TableType
+	Memory    Limits
+	Global    GlobalType
+}
+
+// Export is an entry of the export section.
+type Export struct {
+	Name  string
+	Kind  ExternKind
+	Index uint32
+}
+
+// Global is a global defined by the module.
+type Global struct {
+	Type GlobalType
+	// The constant expression the global is initialized with, without its
+	// final `end`.
+	Init []Instr
+}
+
+// ElemMode is the mode of an element segment.
+type ElemMode int
+
+const (
+	ElemActive ElemMode = iota
+	ElemPassive
+	ElemDeclared
+)
+
+// Elem is an element segment.
+type Elem struct {
+	Mode ElemMode
+	// The table and offset expression of active segments.
+	Table  uint32
+	Offset []Instr
+	Type   ValType
+	// Segments are encoded either as function indices in `Funcs`, or as
+	// constant expressions in `Exprs` if `UsesExprs` is set.
+	Funcs     []uint32
+	Exprs     [][]Instr
+	UsesExprs bool
+}
+
+// Data is a data segment.
+type Data struct {
+	Active bool
+	// The memory and offset expression of active segments.
+	Memory uint32
+	Offset []Instr
+	Init   []byte
+}
+
+// Locals declares `Count` locals of the same type.
+type Locals struct {
+	Count uint32
+	Type  ValType
+}
+
+// Body is the body of a function defined by the module.
+type Body struct {
+	Locals []Locals
+	// The encoded instructions, decoded with `Instrs`.
+	Code []byte
+	// The offset of `Code` within the module.
+	Offset int
+}
+
+// CustomSection is a custom section, whose contents are left to its users.
+type CustomSection struct {
+	Name string
+	Data []byte
+}
+
+// Names holds the contents of the `name` custom section, mapping indices to
+// names for each index space.
+type Names struct {
+	Module *string
+	Funcs  map[uint32]string
+	// The names of the locals of each function, by function index.
+	Locals   map[uint32]map[uint32]string
+	Types    map[uint32]string
+	Tables   map[uint32]string
+	Memories map[uint32]string
+	Globals  map[uint32]string
+	Elems    map[uint32]string
+	Datas    map[uint32]string
+	Tags     map[uint32]string
+}
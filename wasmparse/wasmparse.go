@@ -0,0 +1,311 @@
+// Package wasmparse decodes the WebAssembly binary format in pure Go.
+//
+// It doesn't need the wasmtime library, so modules can be inspected on
+// machines where it isn't installed, such as in build pipelines. The decoder
+// checks the structure of the binary format but doesn't validate modules:
+// a module which parses may still be rejected when compiled.
+//
+// Instructions are supported up to the bulk memory, reference types and tail
+// call proposals. Modules using others, such as SIMD or GC, fail to parse
+// where those are encountered.
+package wasmparse
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Section locates a section within the module.
+type Section struct {
+	ID byte
+	// The offset and size of the section's contents, after its header.
+	Offset int
+	Size   int
+}
+
+// Module is a decoded module. Index spaces which can be imported, such as
+// functions, start with the imports of that kind, followed by the
+// definitions here.
+type Module struct {
+	Types   []FuncType
+	Imports []Import
+	// The type index of each function defined by the module.
+	Funcs    []uint32
+	Tables   []TableType
+	Memories []Limits
+	Globals  []Global
+	Exports  []Export
+	Start    *uint32
+	Elems    []Elem
+	Datas    []Data
+	// The bodies of the functions in `Funcs`.
+	Bodies []Body
+	// The type index of each tag defined by the module.
+	Tags     []uint32
+	Customs  []CustomSection
+	Names    Names
+	Sections []Section
+}
+
+// ImportCount returns the number of imports of the given kind, which come
+// first in that kind's index space.
+func (m *Module) ImportCount(kind ExternKind) uint32 {
+	n := uint32(0)
+	for _, imp := range m.Imports {
+		if imp.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// CustomSections returns the contents of the custom sections called `name`,
+// in the order they appear in the module.
+func (m *Module) CustomSections(name string) [][]byte {
+	var ret [][]byte
+	for _, custom := range m.Customs {
+		if custom.Name == name {
+			ret = append(ret, custom.Data)
+		}
+	}
+	return ret
+}
+
+// ErrComponent is returned by `Parse` for components, which aren't supported.
+var ErrComponent = errors.New("components are not supported, only core modules")
+
+// MaxLocals is the most locals a function may declare, matching the limit
+// enforced by wasmtime.
+const MaxLocals = 50000
+
+// Parse decodes the module in `wasm`. The returned module refers to `wasm`
+// for custom sections, data segments and function bodies, so it must not be
+// modified while the module is in use.
+func Parse(wasm []byte) (*Module, error) {
+	r := &reader{data: wasm}
+	if magic := r.bytes(4); r.err != nil || string(magic) != "\x00asm" {
+		return nil, errors.New("not a WebAssembly binary: bad magic number")
+	}
+	version := r.bytes(4)
+	if r.err != nil {
+		return nil, r.err
+	}
+	switch string(version) {
+	case "\x01\x00\x00\x00":
+	case "\x0d\x00\x01\x00":
+		return nil, ErrComponent
+	default:
+		return nil, fmt.Errorf("unsupported binary version %x", version)
+	}
+
+	m := &Module{}
+	var lastID byte
+	for !r.done() {
+		id := r.byte()
+		size := r.u32()
+		s := r.sub(size)
+		if r.err != nil {
+			return nil, r.err
+		}
+		if id != 0 {
+			// Non-custom sections must appear at most once and in order,
+			// except that data count and tag sections go in between.
+			if sectionOrder(id) <= sectionOrder(lastID) {
+				return nil, fmt.Errorf("offset 0x%x: section %d out of order", s.base, id)
+			}
+			lastID = id
+		}
+		m.Sections = append(m.Sections, Section{ID: id, Offset: s.base, Size: len(s.data)})
+		m.parseSection(id, s)
+		if s.err == nil && !s.done() {
+			s.fail("section size mismatch")
+		}
+		if s.err != nil {
+			return nil, s.err
+		}
+	}
+	if len(m.Funcs) != len(m.Bodies) {
+		return nil, fmt.Errorf("function and code section have inconsistent lengths")
+	}
+	return m, nil
+}
+
+// sectionOrder returns the position at which section `id` may appear.
+func sectionOrder(id byte) int {
+	switch id {
+	case 0:
+		return 0
+	case 13: // tag
+		return 65
+	case 12: // data count
+		return 95
+	default:
+		return int(id) * 10
+	}
+}
+
+func (m *Module) parseSection(id byte, r *reader) {
+	switch id {
+	case 0:
+		name := r.name()
+		data := r.bytes(uint32(len(r.data) - r.pos))
+		m.Customs = append(m.Customs, CustomSection{Name: name, Data: data})
+		if name == "name" && r.err == nil {
+			// The name section is only a debugging aid, so a malformed one
+			// is ignored rather than failing the whole module.
+			if names, err := ParseNames(data); err == nil {
+				m.Names = *names
+			}
+		}
+	case 1:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			m.Types = append(m.Types, r.funcType())
+		}
+	case 2:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			imp := Import{Module: r.name(), Name: r.name(), Kind: ExternKind(r.byte())}
+			switch imp.Kind {
+			case ExternFunc:
+				imp.TypeIndex = r.u32()
+			case ExternTable:
+				imp.Table = r.tableType()
+			case ExternMemory:
+				imp.Memory = r.limits()
+			case ExternGlobal:
+				imp.Global = r.globalType()
+			case ExternTag:
+				r.tagType()
+				imp.TypeIndex = r.u32()
+			default:
+				r.fail("unknown import kind 0x%x", byte(imp.Kind))
+			}
+			m.Imports = append(m.Imports, imp)
+		}
+	case 3:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			m.Funcs = append(m.Funcs, r.u32())
+		}
+	case 4:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			if r.pos < len(r.data) && r.data[r.pos] == 0x40 {
+				r.fail("tables with initializers are not supported")
+			}
+			m.Tables = append(m.Tables, r.tableType())
+		}
+	case 5:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			m.Memories = append(m.Memories, r.limits())
+		}
+	case 6:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			m.Globals = append(m.Globals, Global{Type: r.globalType(), Init: r.expr()})
+		}
+	case 7:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			export := Export{Name: r.name(), Kind: ExternKind(r.byte()), Index: r.u32()}
+			if export.Kind > ExternTag {
+				r.fail("unknown export kind 0x%x", byte(export.Kind))
+			}
+			m.Exports = append(m.Exports, export)
+		}
+	case 8:
+		start := r.u32()
+		m.Start = &start
+	case 9:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			m.Elems = append(m.Elems, r.elemSegment())
+		}
+	case 10:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			body := r.sub(r.u32())
+			m.Bodies = append(m.Bodies, body.body())
+			r.err = body.err
+		}
+	case 11:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			m.Datas = append(m.Datas, r.dataSegment())
+		}
+	case 12:
+		r.u32()
+	case 13:
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			r.tagType()
+			m.Tags = append(m.Tags, r.u32())
+		}
+	default:
+		r.fail("unknown section %d", id)
+	}
+}
+
+// Instrs decodes the instructions of the function body, including the final
+// `end`.
+func (b *Body) Instrs() ([]Instr, error) {
+	r := &reader{data: b.Code, base: b.Offset}
+	var instrs []Instr
+	depth := 0
+	for r.err == nil {
+		if r.done() {
+			r.fail("function body must end with `end`")
+			break
+		}
+		instr := r.instr()
+		instrs = append(instrs, instr)
+		switch instr.Op {
+		case OpBlock, OpLoop, OpIf:
+			depth++
+		case OpEnd:
+			depth--
+		}
+		if depth < 0 {
+			if !r.done() {
+				r.fail("operators remaining after end of function")
+			}
+			break
+		}
+	}
+	return instrs, r.err
+}
+
+// ParseNames decodes the contents of a `name` custom section. Subsections
+// which `Names` doesn't hold are skipped.
+func ParseNames(data []byte) (*Names, error) {
+	var names Names
+	if err := names.parse(&reader{data: data}); err != nil {
+		return nil, fmt.Errorf("malformed name section: %w", err)
+	}
+	return &names, nil
+}
+
+func (n *Names) parse(r *reader) error {
+	for !r.done() {
+		id := r.byte()
+		s := r.sub(r.u32())
+		switch id {
+		case 0:
+			name := s.name()
+			n.Module = &name
+		case 1:
+			n.Funcs = s.nameMap()
+		case 2:
+			n.Locals = s.indirectNameMap()
+		case 4:
+			n.Types = s.nameMap()
+		case 5:
+			n.Tables = s.nameMap()
+		case 6:
+			n.Memories = s.nameMap()
+		case 7:
+			n.Globals = s.nameMap()
+		case 8:
+			n.Elems = s.nameMap()
+		case 9:
+			n.Datas = s.nameMap()
+		case 11:
+			n.Tags = s.nameMap()
+		}
+		if s.err != nil {
+			return s.err
+		}
+	}
+	return r.err
+}
@@ -0,0 +1,114 @@
+package wasmparse
+
+import (
+	"testing"
+
+	"github.com/hybridgroup/wasmtime/internal/wasmtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	wasm := wasmtest.Module(
+		wasmtest.Section(1, wasmtest.Vec([]byte{0x60, 1, 0x7f, 1, 0x6f})),
+		wasmtest.Section(2, wasmtest.Vec(
+			wasmtest.Cat(wasmtest.Name("env"), wasmtest.Name("f"), []byte{0x00, 0}),
+			wasmtest.Cat(wasmtest.Name("env"), wasmtest.Name("mem"), []byte{0x02, 0x05, 1, 2}),
+		)),
+		wasmtest.Section(3, wasmtest.Vec([]byte{0})),
+		wasmtest.Section(6, wasmtest.Vec([]byte{0x7e, 0, 0x42, 0x7f, 0x0b})),
+		wasmtest.Section(7, wasmtest.Vec(wasmtest.Cat(wasmtest.Name("g"), []byte{0x00, 1}))),
+		wasmtest.Section(10, wasmtest.Vec(wasmtest.Cat(wasmtest.ULEB(6), []byte{1, 2, 0x7f, 0x20, 0, 0x0b}))),
+		wasmtest.Section(0, wasmtest.Name("manifest"), []byte("one")),
+		wasmtest.Section(0, wasmtest.Name("manifest"), []byte("two")),
+		wasmtest.Section(0, wasmtest.Name("name"), wasmtest.Section(1, wasmtest.Vec(wasmtest.Cat([]byte{1}, wasmtest.Name("g"))))),
+	)
+	m, err := Parse(wasm)
+	require.NoError(t, err)
+
+	require.Len(t, m.Types, 1)
+	require.Equal(t, "(func (param i32) (result externref))", m.Types[0].String())
+
+	require.Len(t, m.Imports, 2)
+	require.Equal(t, Import{Module: "env", Name: "f", Kind: ExternFunc}, m.Imports[0])
+	require.Equal(t, ExternMemory, m.Imports[1].Kind)
+	require.Equal(t, Limits{Min: 1, Max: 2, HasMax: true, Is64: true}, m.Imports[1].Memory)
+	require.Equal(t, uint32(1), m.ImportCount(ExternFunc))
+	require.Equal(t, uint32(0), m.ImportCount(ExternTable))
+
+	require.Equal(t, []uint32{0}, m.Funcs)
+	require.Len(t, m.Globals, 1)
+	require.Equal(t, GlobalType{Type: ValType{Code: TypeI64}}, m.Globals[0].Type)
+	require.Len(t, m.Globals[0].Init, 1)
+	require.Equal(t, "i64.const", m.Globals[0].Init[0].Op.Name)
+	require.Equal(t, int64(-1), int64(m.Globals[0].Init[0].Value))
+
+	require.Equal(t, []Export{{Name: "g", Kind: ExternFunc, Index: 1}}, m.Exports)
+
+	require.Len(t, m.Bodies, 1)
+	require.Equal(t, []Locals{{Count: 2, Type: ValType{Code: TypeI32}}}, m.Bodies[0].Locals)
+	instrs, err := m.Bodies[0].Instrs()
+	require.NoError(t, err)
+	require.Len(t, instrs, 2)
+	require.Equal(t, "local.get", instrs[0].Op.Name)
+	require.Same(t, OpEnd, instrs[1].Op)
+
+	require.Equal(t, [][]byte{[]byte("one"), []byte("two")}, m.CustomSections("manifest"))
+	require.Nil(t, m.CustomSections("missing"))
+	require.Equal(t, map[uint32]string{1: "g"}, m.Names.Funcs)
+
+	require.Len(t, m.Sections, 9)
+	require.Equal(t, byte(1), m.Sections[0].ID)
+	require.Equal(t, 10, m.Sections[0].Offset)
+	require.Equal(t, 6, m.Sections[0].Size)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		wasm []byte
+		err  string
+	}{
+		{"magic", []byte("\x00wasm"), "bad magic"},
+		{"version", []byte("\x00asm\x02\x00\x00\x00"), "version"},
+		{"truncated", wasmtest.Module([]byte{1, 5, 1}), "unexpected end"},
+		{"order", wasmtest.Module(wasmtest.Section(3, wasmtest.Vec()), wasmtest.Section(1, wasmtest.Vec())), "out of order"},
+		{"size", wasmtest.Module(wasmtest.Section(1, wasmtest.Vec(), []byte{0})), "size mismatch"},
+		{"leb", wasmtest.Module(wasmtest.Section(3, wasmtest.Vec([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0}))), "too long"},
+		{"u32", wasmtest.Module(wasmtest.Section(3, wasmtest.Vec([]byte{0xff, 0xff, 0xff, 0xff, 0x1f}))), "too large"},
+		{"utf8", wasmtest.Module(wasmtest.Section(0, wasmtest.Name("\xff"))), "UTF-8"},
+		{"bodies", wasmtest.Module(wasmtest.Section(3, wasmtest.Vec([]byte{0}))), "inconsistent"},
+		{"valtype", wasmtest.Module(wasmtest.Section(1, wasmtest.Vec([]byte{0x60, 1, 0x01, 0}))), "value type"},
+		{"locals", wasmtest.Module(wasmtest.Section(3, wasmtest.Vec([]byte{0})), wasmtest.Section(10, wasmtest.Vec(wasmtest.Cat(wasmtest.ULEB(6), []byte{1}, wasmtest.ULEB(MaxLocals+1), []byte{0x7f, 0x0b})))), "too many locals"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.wasm)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	_, err := Parse([]byte("\x00asm\x0d\x00\x01\x00"))
+	require.ErrorIs(t, err, ErrComponent)
+}
+
+func TestParseMalformedNames(t *testing.T) {
+	// A malformed name section is ignored.
+	m, err := Parse(wasmtest.Module(wasmtest.Section(0, wasmtest.Name("name"), []byte{1, 5, 1})))
+	require.NoError(t, err)
+	require.Nil(t, m.Names.Funcs)
+	require.Len(t, m.CustomSections("name"), 1)
+}
+
+func TestParseNames(t *testing.T) {
+	names, err := ParseNames(wasmtest.Cat(
+		wasmtest.Section(0, wasmtest.Name("m")),
+		wasmtest.Section(2, wasmtest.Vec(wasmtest.Cat([]byte{3}, wasmtest.Vec(wasmtest.Cat([]byte{0}, wasmtest.Name("x")))))),
+		wasmtest.Section(3, wasmtest.Vec()),
+	))
+	require.NoError(t, err)
+	require.Equal(t, "m", *names.Module)
+	require.Equal(t, map[uint32]map[uint32]string{3: {0: "x"}}, names.Locals)
+	require.Nil(t, names.Funcs)
+
+	_, err = ParseNames([]byte{1, 5})
+	require.ErrorContains(t, err, "malformed name section")
+}
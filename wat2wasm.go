@@ -13,8 +13,15 @@ type wasm_byte_vec_t struct {
 // Wat2Wasm converts the text format of WebAssembly to the binary format.
 //
 // Takes the text format in-memory as input, and returns either the binary
-// encoding of the text format or an error if parsing fails.
+// encoding of the text format or an error if parsing fails. If the loaded
+// library was built without `FeatureWat` an `*UnsupportedError` is returned.
 func Wat2Wasm(wat string) ([]byte, error) {
+	if err := Load(LoadOptions{}); err != nil {
+		return nil, err
+	}
+	if err := checkFeature(FeatureWat); err != nil {
+		return nil, err
+	}
 	var retVec wasm_byte_vec_t
 	err := wasmtime_wat2wasm(wat, len(wat), &retVec)
 	runtime.KeepAlive(wat)